@@ -0,0 +1,194 @@
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+// Admin performs administrative operations on a lock, regardless of its owner.
+// Every operation uses a single get & update, relying on the resource version
+// of the resource for compare-and-swap.
+// Lock data with an invalid signature can be changed by an Admin.
+type Admin struct {
+	lock            *kubeLock
+	expectedVersion string
+}
+
+// NewAdmin creates a new Admin for the lock stored in the annotation with given key.
+// Pass the same options as the instances competing for the lock use, so new lock data
+// is signed and the history is recorded.
+func NewAdmin(annotationKey string, metaGet MetaGetter, metaUpdate MetaUpdater, options ...Option) (*Admin, error) {
+	l, err := NewKubeLock(annotationKey, "admin", 0, metaGet, metaUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &Admin{
+		lock: l.impl(),
+	}, nil
+}
+
+// Get fetches the current lock data and the resource version of the resource that holds it.
+// It returns false if there is no lock data.
+func (a *Admin) Get() (LockData, string, bool, error) {
+	ann, rv, _, err := a.lock.getMeta()
+	if err != nil {
+		return LockData{}, "", false, maskAny(err)
+	}
+	lockData, found, err := a.read(ann)
+	if err != nil {
+		return LockData{}, "", false, maskAny(err)
+	}
+	return lockData, rv, found, nil
+}
+
+// Expect returns an Admin that only makes changes while the resource that holds the lock
+// still has the given resource version, as returned by Get.
+// Otherwise changes fail with a LockChangedError, so a lock is never changed based on
+// lock data that someone has not seen, e.g. when asking for confirmation.
+func (a *Admin) Expect(resourceVersion string) *Admin {
+	return &Admin{
+		lock:            a.lock,
+		expectedVersion: resourceVersion,
+	}
+}
+
+// Break clears the owner of the lock, so it can be acquired right away.
+// User data and the frozen state are kept.
+func (a *Admin) Break(reason string) error {
+	if err := a.update(func(lockData LockData, found bool, now time.Time) (LockData, HistoryReason, error) {
+		if !found || lockData.Owner == "" {
+			return LockData{}, "", maskAny(fmt.Errorf("lock is not owned"))
+		}
+		return LockData{Data: lockData.Data, Frozen: lockData.Frozen, Reason: reason}, HistoryReasonBroken, nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Transfer makes the given owner the holder of the lock for the given ttl.
+// The new owner keeps holding the lock by acquiring it before it expires.
+func (a *Admin) Transfer(owner string, ttl time.Duration, reason string) error {
+	if owner == "" {
+		return maskAny(fmt.Errorf("owner cannot be empty"))
+	}
+	if ttl <= 0 {
+		return maskAny(fmt.Errorf("ttl must be positive"))
+	}
+	if err := a.update(func(lockData LockData, found bool, now time.Time) (LockData, HistoryReason, error) {
+		if lockData.Frozen {
+			return LockData{}, "", maskAny(fmt.Errorf("lock is frozen"))
+		}
+		return LockData{
+			Owner:      owner,
+			ExpiresAt:  now.Add(ttl),
+			AcquiredAt: now,
+			Data:       lockData.Data,
+			Reason:     reason,
+		}, HistoryReasonTransferred, nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Freeze prevents everyone, including the current owner, from acquiring or renewing the lock
+// until it is unfrozen. The current owner keeps the lock until it expires.
+func (a *Admin) Freeze(reason string) error {
+	if err := a.update(func(lockData LockData, found bool, now time.Time) (LockData, HistoryReason, error) {
+		lockData.Frozen = true
+		lockData.Reason = reason
+		return lockData, "", nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Unfreeze allows the lock to be acquired again.
+func (a *Admin) Unfreeze(reason string) error {
+	if err := a.update(func(lockData LockData, found bool, now time.Time) (LockData, HistoryReason, error) {
+		if !lockData.Frozen {
+			return LockData{}, "", maskAny(fmt.Errorf("lock is not frozen"))
+		}
+		lockData.Frozen = false
+		lockData.Reason = reason
+		return lockData, "", nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// update fetches the lock data, changes it using the given function and stores it.
+// If the function returns a history reason and the lock was owned by someone,
+// the end of that hold is added to the history.
+func (a *Admin) update(change func(lockData LockData, found bool, now time.Time) (LockData, HistoryReason, error)) error {
+	l := a.lock
+
+	// Get current state
+	ann, rv, extra, err := l.getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+	if a.expectedVersion != "" && rv != a.expectedVersion {
+		return maskAny(errgo.WithCausef(nil, LockChangedError, "resource version is %s, expected %s", rv, a.expectedVersion))
+	}
+
+	// Update lock data
+	if ann == nil {
+		ann = make(map[string]string)
+	}
+	lockData, found, err := a.read(ann)
+	if err != nil {
+		return maskAny(err)
+	}
+	now := l.clock.Now()
+	newLockData, reason, err := change(lockData, found, now)
+	if err != nil {
+		return maskAny(err)
+	}
+	if reason != "" && lockData.Owner != "" {
+		end, err := l.holdEnd(ann, lockData)
+		if err != nil {
+			return maskAny(err)
+		}
+		endedAt := now
+		if !now.Before(end) {
+			// The hold ended before the change
+			reason = HistoryReasonExpired
+			if !end.IsZero() {
+				endedAt = end
+			}
+		}
+		if err := l.appendHistory(ann, lockData, endedAt, reason); err != nil {
+			return maskAny(err)
+		}
+	}
+	newLockData.Signature = ""
+	if err := l.writeLockData(ann, &newLockData); err != nil {
+		return maskAny(err)
+	}
+
+	// Try to store it now
+	if err := l.updateMeta(ann, rv, extra); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// read decodes the lock data in the given annotations, without verifying its signature.
+func (a *Admin) read(ann map[string]string) (LockData, bool, error) {
+	raw := ann[a.lock.annotationKey]
+	if raw == "" {
+		return LockData{}, false, nil
+	}
+	var lockData LockData
+	if err := json.Unmarshal([]byte(raw), &lockData); err != nil {
+		return LockData{}, false, maskAny(err)
+	}
+	return lockData, true, nil
+}
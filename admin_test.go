@@ -0,0 +1,58 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// TestAdminExpect checks that an Admin created by Expect does not change a lock
+// that has changed since the given resource version.
+func TestAdminExpect(t *testing.T) {
+	get, update := newTestObject(t)
+	first, err := lock.NewKubeLock("", "first", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := first.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	admin, err := lock.NewAdmin("", get, update)
+	if err != nil {
+		t.Fatalf("NewAdmin failed: %v", err)
+	}
+	lockData, rv, found, err := admin.Get()
+	if err != nil || !found || lockData.Owner != "first" {
+		t.Fatalf("Expected lock data of 'first', got %v, %v (%v)", lockData, found, err)
+	}
+
+	// Ownership changes while the operator is confirming
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	second, err := lock.NewKubeLock("", "second", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := second.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := admin.Expect(rv).Break("stuck"); !lock.IsLockChanged(err) {
+		t.Fatalf("Expected LockChanged, got %v", err)
+	}
+	if owner, err := second.CurrentOwner(); err != nil || owner != "second" {
+		t.Fatalf("Expected owner 'second', got '%s' (%v)", owner, err)
+	}
+
+	// Confirmed again
+	if _, rv, _, err = admin.Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := admin.Expect(rv).Break("stuck"); err != nil {
+		t.Fatalf("Break failed: %v", err)
+	}
+	if owner, err := second.CurrentOwner(); err != nil || owner != "" {
+		t.Fatalf("Expected no owner, got '%s' (%v)", owner, err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+// adminArgs holds the flags shared by all admin commands.
+type adminArgs struct {
+	client        *clientArgs
+	resource      *resourceArgs
+	annotationKey string
+	reason        string
+	yes           bool
+	signingSecret string
+	historySize   int
+}
+
+// addAdminFlags adds the flags shared by all admin commands to the given flag set.
+func addAdminFlags(fs *flag.FlagSet) *adminArgs {
+	a := &adminArgs{
+		client:   addClientFlags(fs),
+		resource: addResourceFlags(fs),
+	}
+	fs.StringVar(&a.annotationKey, "annotation-key", "", "Key of the annotation holding the lock data. Defaults to pulcy.com/kube-lock")
+	fs.StringVar(&a.reason, "reason", "", "Reason for the change, stored in the lock data (required)")
+	fs.BoolVar(&a.yes, "yes", false, "Do not ask for confirmation")
+	fs.StringVar(&a.signingSecret, "signing-secret", "", "Secret holding the signing key of the lock, as <name>/<key>")
+	fs.IntVar(&a.historySize, "history-size", 0, "Number of history entries kept, if the lock records its history")
+	return a
+}
+
+// newAdmin validates the admin flags and creates an Admin for the selected lock.
+// It returns a description of the lock as well.
+func (a *adminArgs) newAdmin() (*lock.Admin, string, error) {
+	if a.reason == "" {
+		return nil, "", maskAny(fmt.Errorf("-reason not set"))
+	}
+	kind, name, err := a.resource.parse(a.client.namespace)
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	c, err := a.client.newClient()
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	options, err := a.options(c)
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	get, update, err := k8s.NewMeta(kind, a.client.namespace, name, c)
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	admin, err := lock.NewAdmin(a.annotationKey, get, update, options...)
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	annotationKey := a.annotationKey
+	if annotationKey == "" {
+		annotationKey = defaultAnnotationPrefix
+	}
+	description := fmt.Sprintf("%s on %s/%s/%s", annotationKey, kind, a.client.namespace, name)
+	if kind.IsClusterScoped() {
+		description = fmt.Sprintf("%s on %s/%s", annotationKey, kind, name)
+	}
+	return admin, description, nil
+}
+
+// options returns the lock options selected by the admin flags.
+func (a *adminArgs) options(c *kc.Client) ([]lock.Option, error) {
+	var options []lock.Option
+	if a.signingSecret != "" {
+		parts := strings.SplitN(a.signingSecret, "/", 2)
+		if len(parts) != 2 {
+			return nil, maskAny(fmt.Errorf("-signing-secret must be <name>/<key>"))
+		}
+		key, err := k8s.LoadSigningKey(a.client.namespace, parts[0], parts[1], c)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		options = append(options, lock.WithSigningKey(key, lock.SignaturePolicyCorrupt))
+	}
+	if a.historySize > 0 {
+		options = append(options, lock.WithHistory("", a.historySize))
+	}
+	return options, nil
+}
+
+// confirm describes the change & the current lock data and asks the user to confirm it,
+// unless -yes was given. It returns false if the change must not be made.
+// Otherwise it returns an Admin that only makes the change if the lock data is still the
+// data that was confirmed.
+func (a *adminArgs) confirm(admin *lock.Admin, change string) (*lock.Admin, bool, error) {
+	if a.yes {
+		return admin, true, nil
+	}
+	lockData, rv, found, err := admin.Get()
+	if err != nil {
+		return nil, false, maskAny(err)
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", change)
+	if found {
+		fmt.Fprintf(os.Stderr, "Current owner: %s, expires at %s", lockData.Owner, lockData.ExpiresAt.Format(time.RFC3339))
+		if lockData.Frozen {
+			fmt.Fprintf(os.Stderr, ", frozen (%s)", lockData.Reason)
+		}
+		fmt.Fprintln(os.Stderr)
+	} else {
+		fmt.Fprintf(os.Stderr, "There is no lock data\n")
+	}
+	fmt.Fprintf(os.Stderr, "Continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return admin.Expect(rv), answer == "y" || answer == "yes", nil
+}
+
+// runAdmin parses the flags of an admin command, asks for confirmation and makes the change.
+func runAdmin(name string, args []string, addFlags func(fs *flag.FlagSet), describe func(lock string) string, change func(admin *lock.Admin, reason string) error) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	a := addAdminFlags(fs)
+	if addFlags != nil {
+		addFlags(fs)
+	}
+	fs.Parse(args)
+
+	admin, description, err := a.newAdmin()
+	if err != nil {
+		return maskAny(err)
+	}
+	admin, ok, err := a.confirm(admin, describe(description))
+	if err != nil {
+		return maskAny(err)
+	} else if !ok {
+		return maskAny(fmt.Errorf("aborted"))
+	}
+	if err := change(admin, a.reason); err != nil {
+		if lock.IsLockChanged(err) {
+			return maskAny(errgo.Notef(err, "lock changed while waiting for confirmation, try again"))
+		}
+		return maskAny(err)
+	}
+	return nil
+}
+
+// runBreak clears the owner of a lock.
+func runBreak(args []string) error {
+	return runAdmin("break", args, nil, func(l string) string {
+		return fmt.Sprintf("Break lock %s", l)
+	}, func(admin *lock.Admin, reason string) error {
+		return admin.Break(reason)
+	})
+}
+
+// runTransfer makes another owner the holder of a lock.
+func runTransfer(args []string) error {
+	var owner string
+	var ttl time.Duration
+	return runAdmin("transfer", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&owner, "to", "", "Owner ID of the new owner (required)")
+		fs.DurationVar(&ttl, "ttl", time.Second*30, "Time the new owner has to renew the lock")
+	}, func(l string) string {
+		return fmt.Sprintf("Transfer lock %s to %s", l, owner)
+	}, func(admin *lock.Admin, reason string) error {
+		return admin.Transfer(owner, ttl, reason)
+	})
+}
+
+// runFreeze prevents everyone from acquiring a lock.
+func runFreeze(args []string) error {
+	return runAdmin("freeze", args, nil, func(l string) string {
+		return fmt.Sprintf("Freeze lock %s", l)
+	}, func(admin *lock.Admin, reason string) error {
+		return admin.Freeze(reason)
+	})
+}
+
+// runUnfreeze allows a frozen lock to be acquired again.
+func runUnfreeze(args []string) error {
+	return runAdmin("unfreeze", args, nil, func(l string) string {
+		return fmt.Sprintf("Unfreeze lock %s", l)
+	}, func(admin *lock.Admin, reason string) error {
+		return admin.Unfreeze(reason)
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+// exitStatus is returned by a command to exit with the given status, without printing an error.
+type exitStatus int
+
+// Error returns a description of the exit status.
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// runExec acquires a lock, runs a child command while holding it and releases it when the child exits.
+func runExec(args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kube-lock exec [flags] -- <command> [args...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	client := addClientFlags(fs)
+	resource := addResourceFlags(fs)
+	annotationKey := fs.String("annotation-key", "", "Key of the annotation holding the lock data. Defaults to pulcy.com/kube-lock")
+	ownerID := fs.String("owner", "", "Owner ID to use. Defaults to an ID built from the pod environment or hostname")
+	ttl := fs.Duration("ttl", time.Second*30, "Time to live of the lock, it is renewed while the command runs")
+	wait := fs.Bool("wait", false, "Wait until the lock is free, instead of failing when it is held by someone else")
+	timeout := fs.Duration("timeout", 0, "Maximum time to wait for the lock (with -wait). 0 means wait forever")
+	killAfter := fs.Duration("kill-after", time.Second*10, "Time between SIGTERM & SIGKILL when the lock is lost")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	kind, name, err := resource.parse(client.namespace)
+	if err != nil {
+		return maskAny(err)
+	}
+	c, err := client.newClient()
+	if err != nil {
+		return maskAny(err)
+	}
+	l, err := k8s.NewLock(kind, client.namespace, name, c, *annotationKey, *ownerID, *ttl)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Forward signals to the child, or stop waiting for the lock
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Acquire the lock
+	if err := acquire(ctx, l, *wait, *timeout, signals); err != nil {
+		return maskAny(err)
+	}
+
+	// Run the command while holding the lock
+	status, err := runLocked(ctx, l, fs.Args(), *killAfter, signals)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exec: %v\n", err)
+	}
+	if status != 0 {
+		return exitStatus(status)
+	}
+	return nil
+}
+
+// runLocked runs the given command while holding the given lock, forwarding the given signals to it.
+// When the lock is lost, the command is sent SIGTERM, followed by SIGKILL after the given duration.
+// It returns the exit status of the command, or 1 if the command succeeded but the lock failed.
+func runLocked(ctx context.Context, l lock.ExtendedLock, command []string, killAfter time.Duration, signals <-chan os.Signal) (int, error) {
+	status := 0
+	err := lock.Do(ctx, l, func(ctx context.Context) error {
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			status = 127
+			return maskAny(err)
+		}
+		exited := make(chan error, 1)
+		go func() {
+			exited <- cmd.Wait()
+		}()
+		var kill <-chan time.Time
+		for {
+			select {
+			case err := <-exited:
+				status = exitStatusOf(cmd.ProcessState)
+				if _, ok := err.(*exec.ExitError); ok {
+					// Exit status is passed through
+					return nil
+				}
+				return maskAny(err)
+			case sig := <-signals:
+				cmd.Process.Signal(sig)
+			case <-ctx.Done():
+				if kill == nil {
+					fmt.Fprintf(os.Stderr, "Lock lost, stopping %s\n", command[0])
+					cmd.Process.Signal(syscall.SIGTERM)
+					kill = time.After(killAfter)
+				}
+			case <-kill:
+				cmd.Process.Kill()
+			}
+		}
+	})
+	if err != nil {
+		if status == 0 {
+			status = 1
+		}
+		return status, maskAny(err)
+	}
+	return status, nil
+}
+
+// acquire acquires the given lock.
+// If wait is set, it waits until the lock is free, at most for the given timeout (0 means forever),
+// or until a signal is received. Waiters that lose the race for a lock that became free wait again.
+func acquire(ctx context.Context, l lock.ExtendedLock, wait bool, timeout time.Duration, signals <-chan os.Signal) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		err := l.Acquire()
+		if err == nil {
+			return nil
+		}
+		// Another waiter may have updated the lock just before us
+		if !(lock.IsAlreadyLocked(err) || k8s.IsConflict(err)) || !wait {
+			return maskAny(err)
+		}
+		if err := l.WaitUntilFree(ctx); err != nil {
+			if ctx.Err() != nil {
+				return maskAny(errgo.Notef(ctx.Err(), "lock not acquired"))
+			}
+			return maskAny(err)
+		}
+	}
+}
+
+// exitStatusOf returns the exit status of the given process, like a shell does:
+// 128 plus the signal number if the process was killed by a signal.
+func exitStatusOf(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok {
+		if ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ws.ExitStatus()
+	}
+	if state.Success() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+// runList lists all locks stored in resources of a namespace.
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	client := addClientFlags(fs)
+	kindNames := fs.String("kinds", "daemonset,deployment,replicaset,service,configmap", "Comma separated list of kinds of resources to scan")
+	prefix := fs.String("annotation-prefix", defaultAnnotationPrefix, "Prefix of annotations holding lock data")
+	format := fs.String("o", formatTable, "Output format: table, json or yaml")
+	fs.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return maskAny(err)
+	}
+	var kinds []k8s.Kind
+	for _, name := range strings.Split(*kindNames, ",") {
+		kind, err := k8s.ParseKind(strings.TrimSpace(name))
+		if err != nil {
+			return maskAny(err)
+		}
+		kinds = append(kinds, kind)
+	}
+	c, err := client.newClient()
+	if err != nil {
+		return maskAny(err)
+	}
+
+	now := time.Now()
+	var locks []lockInfo
+	for _, kind := range kinds {
+		items, err := k8s.List(kind, client.namespace, c)
+		if err != nil {
+			return maskAny(err)
+		}
+		for _, md := range items {
+			locks = append(locks, readLocks(kind, client.namespace, md.GetName(), md.GetAnnotations(), *prefix, now)...)
+		}
+	}
+	if err := printLocks(os.Stdout, *format, locks, now); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
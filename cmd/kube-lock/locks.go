@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+const (
+	defaultAnnotationPrefix = "pulcy.com/kube-lock"
+	// sessionAnnotationPrefix is the prefix of annotations holding liveness records of sessions.
+	// It starts with the default annotation prefix, but these records are not locks.
+	sessionAnnotationPrefix = "pulcy.com/kube-lock-session."
+)
+
+// Lock states
+const (
+	stateHeld    = "held"
+	stateExpired = "expired"
+	stateFree    = "free"
+	stateFrozen  = "frozen"
+)
+
+// lockInfo is the decoded lock data of a single annotation.
+type lockInfo struct {
+	Kind          k8s.Kind          `json:"kind"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Name          string            `json:"name"`
+	AnnotationKey string            `json:"annotation_key"`
+	Owner         string            `json:"owner"`
+	State         string            `json:"state"`
+	AcquiredAt    time.Time         `json:"acquired_at"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+	Session       string            `json:"session,omitempty"`
+	Data          map[string]string `json:"data,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+}
+
+// resource returns a description of the resource that holds the lock.
+func (i lockInfo) resource() string {
+	if i.Namespace == "" {
+		return string(i.Kind) + "/" + i.Name
+	}
+	return string(i.Kind) + "/" + i.Namespace + "/" + i.Name
+}
+
+// readLocks decodes the lock data of all annotations with a key that starts with the given prefix.
+// Annotations that do not hold lock data, or hold liveness records of sessions, are ignored.
+// The result is ordered by annotation key.
+func readLocks(kind k8s.Kind, namespace, name string, ann map[string]string, prefix string, now time.Time) []lockInfo {
+	if kind.IsClusterScoped() {
+		namespace = ""
+	}
+	var result []lockInfo
+	for key, raw := range ann {
+		if !strings.HasPrefix(key, prefix) || strings.HasPrefix(key, sessionAnnotationPrefix) || raw == "" {
+			continue
+		}
+		var lockData lock.LockData
+		if err := json.Unmarshal([]byte(raw), &lockData); err != nil {
+			// Not lock data
+			continue
+		}
+		info := lockInfo{
+			Kind:          kind,
+			Namespace:     namespace,
+			Name:          name,
+			AnnotationKey: key,
+			Owner:         lockData.Owner,
+			AcquiredAt:    lockData.AcquiredAt,
+			ExpiresAt:     lockData.ExpiresAt,
+			Session:       lockData.Session,
+			Data:          lockData.Data,
+			Reason:        lockData.Reason,
+		}
+		if lockData.Session != "" {
+			// Locks bound to a session expire with the session, or when their hold is limited
+			info.ExpiresAt, _ = lock.ReadHoldEnd(ann, lockData)
+		}
+		switch {
+		case lockData.Frozen:
+			info.State = stateFrozen
+		case info.Owner == "":
+			info.State = stateFree
+		case now.Before(info.ExpiresAt):
+			info.State = stateHeld
+		default:
+			info.State = stateExpired
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AnnotationKey < result[j].AnnotationKey
+	})
+	return result
+}
@@ -0,0 +1,144 @@
+// Command kube-lock inspects and manages locks stored in annotations of Kubernetes resources.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/ghodss/yaml"
+	"github.com/juju/errgo"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+var (
+	maskAny = errgo.MaskFunc(errgo.Any)
+)
+
+// command is a subcommand of kube-lock.
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"status", "Show the locks stored in a resource", runStatus},
+	{"list", "List all locks stored in resources of a namespace", runList},
+	{"watch", "Watch ownership changes of the locks stored in a resource", runWatch},
+	{"exec", "Run a command while holding a lock", runExec},
+	{"break", "Clear the owner of a lock", runBreak},
+	{"transfer", "Make another owner the holder of a lock", runTransfer},
+	{"freeze", "Prevent everyone from acquiring a lock", runFreeze},
+	{"unfreeze", "Allow a frozen lock to be acquired again", runUnfreeze},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				if status, ok := errgo.Cause(err).(exitStatus); ok {
+					os.Exit(int(status))
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the available commands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kube-lock <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'kube-lock <command> -h' for the flags of a command.\n")
+}
+
+// clientArgs holds the flags used to connect to the cluster.
+type clientArgs struct {
+	kubeconfig string
+	namespace  string
+}
+
+// addClientFlags adds the flags used to connect to the cluster to the given flag set.
+func addClientFlags(fs *flag.FlagSet) *clientArgs {
+	a := &clientArgs{}
+	fs.StringVar(&a.kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path of the kubeconfig file. Defaults to the in-cluster configuration or ~/.kube/config")
+	fs.StringVar(&a.namespace, "namespace", "default", "Kubernetes namespace of the resources")
+	return a
+}
+
+// newClient creates a k8s client from the kubeconfig file, or the in-cluster configuration.
+func (a *clientArgs) newClient() (*kc.Client, error) {
+	path := a.kubeconfig
+	if path == "" {
+		if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			c, err := kc.NewInClusterClient()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			return c, nil
+		}
+		path = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var config kc.Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, maskAny(fmt.Errorf("cannot parse kubeconfig %s: %v", path, err))
+	}
+	c, err := kc.NewClient(&config)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return c, nil
+}
+
+// resourceArgs holds the flags used to select a single resource.
+type resourceArgs struct {
+	kind string
+	name string
+}
+
+// addResourceFlags adds the flags used to select a single resource to the given flag set.
+func addResourceFlags(fs *flag.FlagSet) *resourceArgs {
+	a := &resourceArgs{}
+	fs.StringVar(&a.kind, "kind", string(k8s.KindService), "Kind of the resource that holds the lock data")
+	fs.StringVar(&a.name, "name", "", "Name of the resource that holds the lock data")
+	return a
+}
+
+// parse validates the resource flags.
+// For namespaces, the name defaults to the given namespace.
+func (a *resourceArgs) parse(namespace string) (k8s.Kind, string, error) {
+	kind, err := k8s.ParseKind(a.kind)
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	name := a.name
+	if name == "" && kind == k8s.KindNamespace {
+		name = namespace
+	}
+	if name == "" {
+		return "", "", maskAny(fmt.Errorf("-name not set"))
+	}
+	return kind, name, nil
+}
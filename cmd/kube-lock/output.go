@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// validateFormat returns an error if the given output format is not supported.
+func validateFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return nil
+	default:
+		return maskAny(fmt.Errorf("unknown output format '%s', use table, json or yaml", format))
+	}
+}
+
+// printLocks prints the given locks in the given format.
+func printLocks(w io.Writer, format string, locks []lockInfo, now time.Time) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if locks == nil {
+			locks = []lockInfo{}
+		}
+		if err := encoder.Encode(locks); err != nil {
+			return maskAny(err)
+		}
+	case formatYAML:
+		raw, err := yaml.Marshal(locks)
+		if err != nil {
+			return maskAny(err)
+		}
+		if _, err := w.Write(raw); err != nil {
+			return maskAny(err)
+		}
+	default:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, tableHeader)
+		for _, l := range locks {
+			fmt.Fprintln(tw, tableRow(l, now))
+		}
+		if err := tw.Flush(); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+const tableHeader = "RESOURCE\tANNOTATION\tOWNER\tSTATE\tACQUIRED\tEXPIRES"
+
+// tableRow formats the given lock as a row of a table.
+func tableRow(l lockInfo, now time.Time) string {
+	owner := l.Owner
+	if owner == "" {
+		owner = "-"
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", l.resource(), l.AnnotationKey, owner, l.State,
+		formatTime(l.AcquiredAt, now), formatTime(l.ExpiresAt, now))
+}
+
+// formatTime formats the given time relative to now, e.g. "2018-01-01T00:00:00Z (5s ago)".
+func formatTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), -d)
+	}
+	return fmt.Sprintf("%s (in %s)", t.Format(time.RFC3339), d)
+}
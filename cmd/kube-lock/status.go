@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+// runStatus shows the locks stored in a single resource.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	client := addClientFlags(fs)
+	resource := addResourceFlags(fs)
+	prefix := fs.String("annotation-prefix", defaultAnnotationPrefix, "Prefix of annotations holding lock data")
+	format := fs.String("o", formatTable, "Output format: table, json or yaml")
+	fs.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return maskAny(err)
+	}
+	kind, name, err := resource.parse(client.namespace)
+	if err != nil {
+		return maskAny(err)
+	}
+	c, err := client.newClient()
+	if err != nil {
+		return maskAny(err)
+	}
+	get, _, err := k8s.NewMeta(kind, client.namespace, name, c)
+	if err != nil {
+		return maskAny(err)
+	}
+	ann, _, _, err := get()
+	if err != nil {
+		return maskAny(err)
+	}
+	now := time.Now()
+	locks := readLocks(kind, client.namespace, name, ann, *prefix, now)
+	if err := printLocks(os.Stdout, *format, locks, now); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+)
+
+// watchEvent is printed when the owner of a lock changes.
+type watchEvent struct {
+	Time          time.Time `json:"time"`
+	PreviousOwner string    `json:"previous_owner"`
+	lockInfo
+}
+
+// runWatch prints the ownership changes of the locks stored in a single resource,
+// until the process is stopped.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	client := addClientFlags(fs)
+	resource := addResourceFlags(fs)
+	prefix := fs.String("annotation-prefix", defaultAnnotationPrefix, "Prefix of annotations holding lock data")
+	format := fs.String("o", formatTable, "Output format: table, json or yaml")
+	interval := fs.Duration("interval", time.Second*2, "Time between two polls of the resource")
+	fs.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return maskAny(err)
+	}
+	kind, name, err := resource.parse(client.namespace)
+	if err != nil {
+		return maskAny(err)
+	}
+	c, err := client.newClient()
+	if err != nil {
+		return maskAny(err)
+	}
+	get, _, err := k8s.NewMeta(kind, client.namespace, name, c)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Rows are aligned per poll, since each poll is flushed before waiting for the next one
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if *format == formatTable {
+		fmt.Fprintln(w, "TIME\tPREVIOUS\t"+tableHeader)
+	}
+	owners := make(map[string]string)
+	for {
+		ann, _, _, err := get()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot get %s/%s: %v\n", kind, name, err)
+		} else {
+			now := time.Now()
+			seen := make(map[string]bool)
+			for _, l := range readLocks(kind, client.namespace, name, ann, *prefix, now) {
+				// Expired locks are free
+				owner := l.Owner
+				if l.State != stateHeld {
+					owner = ""
+				}
+				seen[l.AnnotationKey] = true
+				previous, known := owners[l.AnnotationKey]
+				if known && previous == owner {
+					continue
+				}
+				owners[l.AnnotationKey] = owner
+				if err := printEvent(w, *format, watchEvent{Time: now, PreviousOwner: previous, lockInfo: l}); err != nil {
+					return maskAny(err)
+				}
+			}
+			for key := range owners {
+				if !seen[key] {
+					// Lock data removed
+					delete(owners, key)
+				}
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return maskAny(err)
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// printEvent prints the given event in the given format.
+func printEvent(w io.Writer, format string, e watchEvent) error {
+	switch format {
+	case formatJSON:
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			return maskAny(err)
+		}
+	case formatYAML:
+		raw, err := yaml.Marshal(e)
+		if err != nil {
+			return maskAny(err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", raw); err != nil {
+			return maskAny(err)
+		}
+	default:
+		previous := e.PreviousOwner
+		if previous == "" {
+			previous = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", e.Time.Format(time.RFC3339), previous, tableRow(e.lockInfo, e.Time)); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package debug provides an HTTP handler that shows the state of all locks
+// known to this process, e.g. at /debug/kubelock.
+package debug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// Registry holds the locks shown by the handler.
+type Registry struct {
+	mutex   sync.Mutex
+	entries []entry
+}
+
+type entry struct {
+	resource string
+	lock     lock.ExtendedLock
+}
+
+// LockStatus is the status of a single lock, as shown by the handler.
+type LockStatus struct {
+	// Resource that holds the lock data.
+	Resource string `json:"resource"`
+	lock.LockState
+	// CurrentOwner is the owner of the lock according to the API server.
+	CurrentOwner string `json:"current_owner"`
+	// OwnerError is set when the current owner could not be fetched.
+	OwnerError string `json:"owner_error,omitempty"`
+}
+
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the given lock to the registry.
+// The resource identifies the resource that holds the lock data, e.g. "namespace/service/name".
+func (r *Registry) Register(resource string, l lock.ExtendedLock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, e := range r.entries {
+		if e.lock == l {
+			return
+		}
+	}
+	r.entries = append(r.entries, entry{resource: resource, lock: l})
+}
+
+// Unregister removes the given lock from the registry.
+func (r *Registry) Unregister(l lock.ExtendedLock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, e := range r.entries {
+		if e.lock == l {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Status returns the status of all registered locks, ordered by resource & annotation key.
+// The current owner of every lock is fetched from the API server.
+func (r *Registry) Status() []LockStatus {
+	r.mutex.Lock()
+	entries := append([]entry(nil), r.entries...)
+	r.mutex.Unlock()
+
+	result := make([]LockStatus, 0, len(entries))
+	for _, e := range entries {
+		status := LockStatus{
+			Resource:  e.resource,
+			LockState: e.lock.State(),
+		}
+		if owner, err := e.lock.CurrentOwner(); err != nil {
+			status.OwnerError = err.Error()
+		} else {
+			status.CurrentOwner = owner
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Resource != result[j].Resource {
+			return result[i].Resource < result[j].Resource
+		}
+		return result[i].AnnotationKey < result[j].AnnotationKey
+	})
+	return result
+}
+
+// NewHandler creates an HTTP handler that shows the status of all locks in the given registry.
+// It responds with JSON when the request has a 'format=json' query parameter or
+// accepts 'application/json', and with HTML otherwise.
+func NewHandler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		status := r.Status()
+		if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := htmlTemplate.Execute(w, status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+var htmlTemplate = template.Must(template.New("locks").Funcs(template.FuncMap{
+	"formatTime": formatTime,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>kube-lock</title></head>
+<body>
+<h1>Locks</h1>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Resource</th><th>Annotation key</th><th>Held</th><th>Current owner</th><th>Our owner ID</th><th>Held until</th><th>Last success</th><th>Last round trip</th><th>Last error</th></tr>
+{{range .}}<tr>
+<td>{{.Resource}}</td>
+<td>{{.AnnotationKey}}</td>
+<td>{{.Held}}</td>
+<td>{{if .OwnerError}}error: {{.OwnerError}}{{else}}{{.CurrentOwner}}{{end}}</td>
+<td>{{.OwnerID}}</td>
+<td>{{formatTime .HeldUntil}}</td>
+<td>{{formatTime .LastSuccess}}</td>
+<td>{{.LastRoundTrip}}</td>
+<td>{{.LastError}}</td>
+</tr>
+{{else}}<tr><td colspan="9">No locks registered</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// formatTime formats the given time for the HTML output, empty for a zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,141 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juju/errgo"
+)
+
+// DoError is returned by Do when the function or the lock failed.
+type DoError struct {
+	// Err is the error returned by the function.
+	Err error
+	// LockErr is the error of the lock. It is set when the lock could not be acquired,
+	// the lock was lost while the function was running or the lock could not be released.
+	LockErr error
+}
+
+// Error returns a description of both errors.
+func (e *DoError) Error() string {
+	switch {
+	case e.Err != nil && e.LockErr != nil:
+		return fmt.Sprintf("%v (lock: %v)", e.Err, e.LockErr)
+	case e.LockErr != nil:
+		return fmt.Sprintf("lock: %v", e.LockErr)
+	default:
+		return e.Err.Error()
+	}
+}
+
+// Cause returns the cause of the lock error, or if not set, the cause of the function error.
+func (e *DoError) Cause() error {
+	if e.LockErr != nil {
+		return errgo.Cause(e.LockErr)
+	}
+	return errgo.Cause(e.Err)
+}
+
+// Do acquires the given lock, runs the given function and releases the lock.
+// While the function runs, the lock is renewed early enough to leave room for several
+// attempts, based on the observed round trip time of the API server.
+// The context passed to the function is canceled as soon as the lock is lost,
+// or the lock can no longer be considered held (see IsHeld).
+// The lock is given up early, with an error caused by RenewalUnsafeError, when the
+// round trip time no longer leaves room for renewal attempts before the lock expires.
+// If the function or the lock failed, a *DoError is returned.
+func Do(ctx context.Context, lock ExtendedLock, fn func(ctx context.Context) error) error {
+	l := lock.impl()
+	if err := l.Acquire(); err != nil {
+		return &DoError{LockErr: maskAny(err)}
+	}
+
+	// Run the function
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(fnCtx)
+	}()
+
+	// Keep the lock while the function runs
+	fnErr, lockErr := l.keep(done)
+	cancel()
+	if fnErr == nil && lockErr != nil {
+		// Wait for the function to notice the cancellation
+		fnErr = <-done
+	}
+
+	// Always release the lock
+	if err := l.Release(); err != nil && lockErr == nil {
+		lockErr = maskAny(err)
+	}
+	if fnErr == nil && lockErr == nil {
+		return nil
+	}
+	return &DoError{Err: fnErr, LockErr: lockErr}
+}
+
+// keep renews the lock until the function reports completion on the given channel,
+// or the lock is lost.
+// If the function completed, its error is returned, otherwise an error caused by LockLostError
+// or RenewalUnsafeError.
+func (l *kubeLock) keep(done <-chan error) (fnErr, lockErr error) {
+	if l.session == nil {
+		if err := l.checkRenewal(); err != nil {
+			// Step down right away
+			return nil, maskAny(err)
+		}
+	}
+	renewAt := l.nextRenewal(l.clock.Now(), false)
+	var renewErr error
+	for {
+		// Wait until the next renewal, or until the lock is no longer safe to hold
+		now := l.clock.Now()
+		wait := renewAt.Sub(now)
+		if l.session != nil {
+			// Locks bound to a session are renewed by the session
+			wait = l.pollInterval
+		}
+		if untilUnsafe := l.currentHeldUntil().Add(-l.safetyMargin).Sub(now); untilUnsafe < wait {
+			wait = untilUnsafe
+		}
+		select {
+		case err := <-done:
+			return err, nil
+		case <-l.after(wait):
+			// Continue
+		}
+
+		// Renew if needed
+		if l.session == nil && !l.clock.Now().Before(renewAt) {
+			if err := l.Acquire(); err != nil {
+				renewErr = err
+				if IsAlreadyLocked(err) || IsMaxHoldExceeded(err) || IsLockFrozen(err) {
+					return nil, maskAny(errgo.WithCausef(err, LockLostError, "renewal failed"))
+				}
+				if now := l.clock.Now(); l.renewalTimeLeft(now) {
+					renewAt = l.nextRenewal(now, true)
+				} else {
+					// Step down before the lock expires
+					return nil, maskAny(errgo.WithCausef(err, RenewalUnsafeError, "no time left for another renewal"))
+				}
+			} else {
+				renewErr = nil
+				renewAt = l.nextRenewal(l.clock.Now(), false)
+			}
+			if err := l.checkRenewal(); err != nil {
+				// Step down while we still hold the lock
+				return nil, maskAny(err)
+			}
+		}
+
+		// Are we still safe?
+		if !l.IsHeld() {
+			if renewErr != nil {
+				return nil, maskAny(errgo.WithCausef(renewErr, LockLostError, "renewal failed"))
+			}
+			return nil, maskAny(errgo.WithCausef(nil, LockLostError, "lock is no longer held"))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package lock_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// runDo runs Do in the background, advancing the given clock by step whenever
+// Do waits for a timer, until Do returns.
+func runDo(t *testing.T, clock *locktest.Clock, step time.Duration, l lock.ExtendedLock, fn func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- lock.Do(context.Background(), l, fn)
+	}()
+	deadline := time.After(time.Second * 10)
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-deadline:
+			t.Fatalf("Do did not return")
+		default:
+		}
+		if clock.Timers() > 0 {
+			clock.Advance(step)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// TestDoReleases checks that Do releases the lock when the function returns,
+// with or without an error.
+func TestDoReleases(t *testing.T) {
+	get, update := newTestObject(t)
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := lock.Do(context.Background(), l, func(ctx context.Context) error {
+		if owner, err := l.CurrentOwner(); err != nil || owner != "owner" {
+			return fmt.Errorf("expected owner 'owner', got '%s' (%v)", owner, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected lock to be released, got owner '%s' (%v)", owner, err)
+	}
+
+	fnErr := fmt.Errorf("function failed")
+	err = lock.Do(context.Background(), l, func(ctx context.Context) error {
+		return fnErr
+	})
+	doErr, ok := err.(*lock.DoError)
+	if !ok {
+		t.Fatalf("Expected *DoError, got %v", err)
+	}
+	if doErr.Err != fnErr || doErr.LockErr != nil {
+		t.Errorf("Expected only the function error, got %v", doErr)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected lock to be released, got owner '%s' (%v)", owner, err)
+	}
+}
+
+// TestDoAlreadyLocked checks that Do does not run the function when the lock is held by another owner.
+func TestDoAlreadyLocked(t *testing.T) {
+	get, update := newTestObject(t)
+	other, err := lock.NewKubeLock("", "other", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := other.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	err = lock.Do(context.Background(), l, func(ctx context.Context) error {
+		t.Errorf("Function must not run")
+		return nil
+	})
+	if !lock.IsAlreadyLocked(err) {
+		t.Errorf("Expected AlreadyLocked, got %v", err)
+	}
+}
+
+// TestDoCancelsOnLockLoss checks that the function is canceled when the lock is lost,
+// and that both the function error and the lock error are reported.
+func TestDoCancelsOnLockLoss(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	admin, err := lock.NewAdmin("", get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewAdmin failed: %v", err)
+	}
+	err = runDo(t, clock, 10*time.Second, l, func(ctx context.Context) error {
+		// Someone else takes over the lock
+		if err := admin.Transfer("other", time.Hour, "test"); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	doErr, ok := err.(*lock.DoError)
+	if !ok {
+		t.Fatalf("Expected *DoError, got %v", err)
+	}
+	if doErr.Err != context.Canceled {
+		t.Errorf("Expected function to be canceled, got %v", doErr.Err)
+	}
+	if !lock.IsLockLost(doErr.LockErr) {
+		t.Errorf("Expected LockLost, got %v", doErr.LockErr)
+	}
+	if msg := err.Error(); !strings.Contains(msg, context.Canceled.Error()) || !strings.Contains(msg, "renewal failed") {
+		t.Errorf("Expected both errors in '%s'", msg)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "other" {
+		t.Errorf("Expected owner 'other', got '%s' (%v)", owner, err)
+	}
+}
+
+// TestDoStepsDownAtMaxHold checks that Do cancels the function and releases the lock
+// once the lock can no longer be renewed because of the maximum hold duration.
+func TestDoStepsDownAtMaxHold(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	start := clock.Now()
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithMaxHoldDuration(5*time.Minute))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	err = runDo(t, clock, 5*time.Second, l, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	if !lock.IsLockLost(err) {
+		t.Fatalf("Expected LockLost, got %v", err)
+	}
+	if elapsed := clock.Now().Sub(start); elapsed > 5*time.Minute {
+		t.Errorf("Expected to step down within the maximum hold duration, took %s", elapsed)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected lock to be released, got owner '%s' (%v)", owner, err)
+	}
+}
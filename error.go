@@ -5,9 +5,16 @@ import (
 )
 
 var (
-	maskAny            = errgo.MaskFunc(errgo.Any)
-	AlreadyLockedError = errgo.New("already locked")
-	NotLockedByMeError = errgo.New("not locked by me")
+	maskAny               = errgo.MaskFunc(errgo.Any)
+	AlreadyLockedError    = errgo.New("already locked")
+	NotLockedByMeError    = errgo.New("not locked by me")
+	MaxHoldExceededError  = errgo.New("maximum hold duration exceeded")
+	InvalidSignatureError = errgo.New("invalid signature")
+	SessionExpiredError   = errgo.New("session expired")
+	LockLostError         = errgo.New("lock lost")
+	RenewalUnsafeError    = errgo.New("renewal unsafe")
+	LockFrozenError       = errgo.New("lock frozen")
+	LockChangedError      = errgo.New("lock changed")
 )
 
 // IsAlreadyLocked returns true if the given error is caused by a AlreadyLockedError error.
@@ -19,3 +26,39 @@ func IsAlreadyLocked(err error) bool {
 func IsNotLockedByMe(err error) bool {
 	return errgo.Cause(err) == NotLockedByMeError
 }
+
+// IsMaxHoldExceeded returns true if the given error is caused by a MaxHoldExceededError error.
+// The holder of the lock must step down and release the lock.
+func IsMaxHoldExceeded(err error) bool {
+	return errgo.Cause(err) == MaxHoldExceededError
+}
+
+// IsInvalidSignature returns true if the given error is caused by a InvalidSignatureError error.
+func IsInvalidSignature(err error) bool {
+	return errgo.Cause(err) == InvalidSignatureError
+}
+
+// IsSessionExpired returns true if the given error is caused by a SessionExpiredError error.
+func IsSessionExpired(err error) bool {
+	return errgo.Cause(err) == SessionExpiredError
+}
+
+// IsLockLost returns true if the given error is caused by a LockLostError error.
+func IsLockLost(err error) bool {
+	return errgo.Cause(err) == LockLostError
+}
+
+// IsRenewalUnsafe returns true if the given error is caused by a RenewalUnsafeError error.
+func IsRenewalUnsafe(err error) bool {
+	return errgo.Cause(err) == RenewalUnsafeError
+}
+
+// IsLockFrozen returns true if the given error is caused by a LockFrozenError error.
+func IsLockFrozen(err error) bool {
+	return errgo.Cause(err) == LockFrozenError
+}
+
+// IsLockChanged returns true if the given error is caused by a LockChangedError error.
+func IsLockChanged(err error) bool {
+	return errgo.Cause(err) == LockChangedError
+}
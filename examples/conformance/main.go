@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	yakhttp "github.com/YakLabs/k8s-client/http"
+	kc "github.com/ericchiang/k8s"
+	"github.com/pulcy/kube-lock/k8s/ericchiang"
+	"github.com/pulcy/kube-lock/k8s/yaklabs"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+var (
+	args struct {
+		namespace   string
+		backend     string
+		simulations int
+		seed        int64
+	}
+)
+
+func init() {
+	flag.StringVar(&args.namespace, "namespace", "", "Kubernetes namespace to create test objects in")
+	flag.StringVar(&args.backend, "backend", "", "Backend to test (memory|lease|ericchiang-<kind>|ericchiang-lease|yaklabs-<kind>). Defaults to all")
+	flag.IntVar(&args.simulations, "simulations", 10, "Number of mutual exclusion simulations to run")
+	flag.Int64Var(&args.seed, "seed", 0, "Seed of the first simulation. Defaults to a time based seed")
+}
+
+// logT implements locktest.T by logging to stderr.
+type logT struct {
+	backend string
+	failed  bool
+}
+
+func (t *logT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+	log.Printf("FAIL ["+t.backend+"] "+format, args...)
+}
+
+func (t *logT) Logf(format string, args ...interface{}) {
+	log.Printf("["+t.backend+"] "+format, args...)
+}
+
+func main() {
+	flag.Parse()
+
+	backends := make(map[string]func() locktest.Backend)
+	names := []string{"memory", "lease"}
+	backends["memory"] = func() locktest.Backend {
+		return locktest.NewMemoryBackend()
+	}
+	backends["lease"] = func() locktest.Backend {
+		return locktest.NewLeaseBackend("")
+	}
+	for _, kind := range ericchiang.Kinds {
+		kind := kind
+		name := "ericchiang-" + string(kind)
+		names = append(names, name)
+		backends[name] = func() locktest.Backend {
+			c, err := kc.NewInClusterClient()
+			if err != nil {
+				log.Fatalf("Cannot create k8s client: %#v\n", err)
+			}
+			b, err := ericchiang.NewConformanceBackend(kind, args.namespace, c)
+			if err != nil {
+				log.Fatalf("Cannot create backend: %#v\n", err)
+			}
+			return b
+		}
+	}
+	names = append(names, "ericchiang-lease")
+	backends["ericchiang-lease"] = func() locktest.Backend {
+		c, err := kc.NewInClusterClient()
+		if err != nil {
+			log.Fatalf("Cannot create k8s client: %#v\n", err)
+		}
+		return ericchiang.NewLeaseConformanceBackend(args.namespace, c)
+	}
+	for _, kind := range yaklabs.ConformanceKinds {
+		kind := kind
+		name := "yaklabs-" + kind
+		names = append(names, name)
+		backends[name] = func() locktest.Backend {
+			c, err := yakhttp.NewInCluster()
+			if err != nil {
+				log.Fatalf("Cannot create k8s client: %#v\n", err)
+			}
+			b, err := yaklabs.NewConformanceBackend(kind, args.namespace, c)
+			if err != nil {
+				log.Fatalf("Cannot create backend: %#v\n", err)
+			}
+			return b
+		}
+	}
+
+	if args.backend != "" {
+		if _, ok := backends[args.backend]; !ok {
+			log.Fatalf("Unknown backend '%s'\n", args.backend)
+		}
+		names = []string{args.backend}
+	}
+	if args.namespace == "" && (len(names) > 1 || (names[0] != "memory" && names[0] != "lease")) {
+		log.Fatalln("-namespace not set")
+	}
+
+	failed := false
+	seed := args.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	for i := 0; i < args.simulations; i++ {
+		result, err := locktest.Simulate(locktest.SimulationConfig{
+			Seed:         seed + int64(i),
+			MaxClockSkew: time.Second,
+			ReleaseRate:  0.1,
+			Faults: locktest.Faults{
+				MaxLatency:      time.Second,
+				GetErrorRate:    0.05,
+				UpdateErrorRate: 0.05,
+				ConflictRate:    0.05,
+				LostReplyRate:   0.05,
+			},
+		})
+		if err != nil {
+			log.Printf("FAIL [simulation] %v\n", err)
+			failed = true
+		} else {
+			log.Printf("[simulation] seed %d: %d hold periods, ok\n", result.Seed, len(result.Periods))
+		}
+	}
+	for _, name := range names {
+		t := &logT{backend: name}
+		locktest.Run(t, backends[name]())
+		failed = failed || t.failed
+	}
+	if failed {
+		os.Exit(1)
+	}
+	log.Println("All backends conform")
+}
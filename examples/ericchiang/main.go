@@ -17,6 +17,7 @@ var (
 		deploymentName string
 		replicaSetName string
 		serviceName    string
+		configMapName  string
 	}
 )
 
@@ -26,6 +27,7 @@ func init() {
 	flag.StringVar(&args.deploymentName, "deployment", "", "Kubernetes namespace of Deployment to store lock data in")
 	flag.StringVar(&args.replicaSetName, "replicaSet", "", "Kubernetes namespace of ReplicaSet to store lock data in")
 	flag.StringVar(&args.serviceName, "service", "", "Kubernetes namespace of Service to store lock data in")
+	flag.StringVar(&args.configMapName, "configMap", "", "Name of ConfigMap to store lock data in")
 }
 
 func main() {
@@ -50,6 +52,8 @@ func main() {
 		l, err = k8s.NewServiceLock(args.namespace, args.serviceName, c, "", "", ttl)
 	} else if args.replicaSetName != "" {
 		l, err = k8s.NewReplicaSetLock(args.namespace, args.replicaSetName, c, "", "", ttl)
+	} else if args.configMapName != "" {
+		l, err = k8s.NewConfigMapLock(args.namespace, args.configMapName, c, "", "", ttl)
 	} else {
 		l, err = k8s.NewNamespaceLock(args.namespace, c, "", "", ttl)
 	}
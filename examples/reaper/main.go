@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	kc "github.com/ericchiang/k8s"
+	lock "github.com/pulcy/kube-lock"
+	k8s "github.com/pulcy/kube-lock/k8s/ericchiang"
+	"github.com/pulcy/kube-lock/reaper"
+)
+
+var (
+	args struct {
+		namespace        string
+		kinds            string
+		annotationPrefix string
+		gracePeriod      time.Duration
+		interval         time.Duration
+	}
+)
+
+func init() {
+	flag.StringVar(&args.namespace, "namespace", "", "Kubernetes namespace to scan")
+	flag.StringVar(&args.kinds, "kinds", "daemonset,deployment,replicaset,service,configmap", "Comma separated list of kinds of resources to scan")
+	flag.StringVar(&args.annotationPrefix, "annotation-prefix", "", "Prefix of annotations holding lock data")
+	flag.DurationVar(&args.gracePeriod, "grace-period", time.Minute*10, "Time that lock data must have been expired before it is cleared")
+	flag.DurationVar(&args.interval, "interval", time.Minute, "Time between scans")
+}
+
+func main() {
+	flag.Parse()
+
+	if args.namespace == "" {
+		log.Fatalln("-namespace not set")
+	}
+	var kinds []k8s.Kind
+	for _, name := range strings.Split(args.kinds, ",") {
+		kind, err := k8s.ParseKind(name)
+		if err != nil {
+			log.Fatalf("Invalid -kinds: %v\n", err)
+		}
+		kinds = append(kinds, kind)
+	}
+
+	c, err := kc.NewInClusterClient()
+	if err != nil {
+		log.Fatalf("Cannot create k8s client: %#v\n", err)
+	}
+
+	prefix := args.annotationPrefix
+	if prefix == "" {
+		prefix = "pulcy.com/kube-lock"
+	}
+	list := func() ([]reaper.Resource, error) {
+		var result []reaper.Resource
+		for _, kind := range kinds {
+			items, err := k8s.List(kind, args.namespace, c)
+			if err != nil {
+				return nil, err
+			}
+			for _, md := range items {
+				if !hasAnnotationPrefix(md.GetAnnotations(), prefix) {
+					continue
+				}
+				get, update, err := k8s.NewMeta(kind, args.namespace, md.GetName(), c)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, reaper.Resource{
+					Name:   string(kind) + "/" + args.namespace + "/" + md.GetName(),
+					Get:    get,
+					Update: update,
+				})
+			}
+		}
+		return result, nil
+	}
+	onReap := func(res reaper.Resource, annotationKey string, lockData lock.LockData) {
+		log.Printf("Cleared %s on %s, owned by %s, expired at %s\n", annotationKey, res.Name, lockData.Owner, lockData.ExpiresAt)
+	}
+
+	onError := func(err error) {
+		log.Printf("Scan failed: %v\n", err)
+	}
+
+	r := reaper.New(reaper.Config{
+		AnnotationPrefix: prefix,
+		GracePeriod:      args.gracePeriod,
+		Interval:         args.interval,
+		OnReap:           onReap,
+		OnError:          onError,
+	}, list)
+	r.Run(context.Background())
+}
+
+// hasAnnotationPrefix returns true if any of the given annotations has a key with given prefix.
+func hasAnnotationPrefix(annotations map[string]string, prefix string) bool {
+	for key := range annotations {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
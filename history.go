@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HistoryReason describes why an owner stopped holding the lock.
+type HistoryReason string
+
+const (
+	// HistoryReasonReleased is used when the owner released the lock.
+	HistoryReasonReleased HistoryReason = "released"
+	// HistoryReasonExpired is used when the lock expired and was acquired by another owner.
+	HistoryReasonExpired HistoryReason = "expired"
+	// HistoryReasonBroken is used when an administrator cleared the lock (see Admin.Break).
+	HistoryReasonBroken HistoryReason = "broken"
+	// HistoryReasonTransferred is used when an administrator transferred the lock to another owner
+	// (see Admin.Transfer).
+	HistoryReasonTransferred HistoryReason = "transferred"
+	// HistoryReasonReaped is used when the lock expired and was cleared by the reaper.
+	HistoryReasonReaped HistoryReason = "reaped"
+)
+
+// HistoryEntry describes a single period in which an owner held the lock.
+type HistoryEntry struct {
+	Owner      string        `json:"owner"`
+	AcquiredAt time.Time     `json:"acquired_at"`
+	EndedAt    time.Time     `json:"ended_at"`
+	Reason     HistoryReason `json:"reason"`
+}
+
+const (
+	// HistorySuffix is appended to the annotation key of the lock when no history key is given.
+	HistorySuffix = ".history"
+	// defaultHistorySize is the number of entries kept when no size is given.
+	defaultHistorySize = 10
+	// maxHistoryBytes limits the size of the history annotation, so it stays well
+	// within the limit on the total size of all annotations of a resource.
+	maxHistoryBytes = 16 * 1024
+)
+
+// History fetches the ownership history of the lock, oldest entry first.
+// The history is only recorded when the lock is created with the WithHistory option.
+func (l *kubeLock) History() ([]HistoryEntry, error) {
+	if l.historyKey == "" {
+		return nil, nil
+	}
+	ann, _, _, err := l.getMeta()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	entries, err := ReadHistory(ann, l.historyKey)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return entries, nil
+}
+
+// ReadHistory reads the ownership history stored in the annotation with given key.
+// If there is no such annotation, nil is returned.
+func ReadHistory(ann map[string]string, historyKey string) ([]HistoryEntry, error) {
+	raw := ann[historyKey]
+	if raw == "" {
+		return nil, nil
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return nil, maskAny(err)
+	}
+	return entries, nil
+}
+
+// appendHistory adds an entry for the given lock data to the history in the given annotations.
+func (l *kubeLock) appendHistory(ann map[string]string, lockData LockData, endedAt time.Time, reason HistoryReason) error {
+	if l.historyKey == "" {
+		return nil
+	}
+	entry := HistoryEntry{
+		Owner:      lockData.Owner,
+		AcquiredAt: lockData.AcquiredAt,
+		EndedAt:    endedAt,
+		Reason:     reason,
+	}
+	if err := AppendHistory(ann, l.historyKey, l.historySize, entry); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// AppendHistory adds the given entry to the ownership history stored in the annotation with given key,
+// keeping at most size entries. The oldest entries are dropped when the history exceeds its size limits.
+func AppendHistory(ann map[string]string, historyKey string, size int, entry HistoryEntry) error {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	entries, err := ReadHistory(ann, historyKey)
+	if err != nil {
+		// Start over with a corrupt history
+		entries = nil
+	}
+	entries = append(entries, entry)
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	for {
+		raw, err := json.Marshal(entries)
+		if err != nil {
+			return maskAny(err)
+		}
+		if len(raw) <= maxHistoryBytes || len(entries) == 1 {
+			ann[historyKey] = string(raw)
+			return nil
+		}
+		entries = entries[1:]
+	}
+}
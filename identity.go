@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables used to build an owner ID.
+// Set them using the downward API, e.g.:
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.name
+const (
+	PodNameEnv      = "POD_NAME"      // metadata.name
+	PodNamespaceEnv = "POD_NAMESPACE" // metadata.namespace
+	PodUIDEnv       = "POD_UID"       // metadata.uid
+	NodeNameEnv     = "NODE_NAME"     // spec.nodeName
+)
+
+const (
+	identitySeparator = "/"
+	identityParts     = 5
+	suffixLength      = 4
+)
+
+// Identity describes the pod that owns a lock.
+// Its string form is used as owner ID and has the format
+// '<namespace>/<name>/<uid>/<node>/<suffix>'. Parts that are unknown are left empty.
+type Identity struct {
+	Namespace string
+	Name      string
+	UID       string
+	Node      string
+	// Suffix is random, such that restarts of the same pod get a different identity.
+	Suffix string
+}
+
+// NewIdentity creates an identity for the current process.
+// The pod is described by the POD_NAME, POD_NAMESPACE, POD_UID & NODE_NAME
+// environment variables. If POD_NAME is not set, the hostname is used instead.
+func NewIdentity() (Identity, error) {
+	suffix := make([]byte, suffixLength)
+	if _, err := rand.Read(suffix); err != nil {
+		return Identity{}, maskAny(err)
+	}
+	id := Identity{
+		Namespace: os.Getenv(PodNamespaceEnv),
+		Name:      os.Getenv(PodNameEnv),
+		UID:       os.Getenv(PodUIDEnv),
+		Node:      os.Getenv(NodeNameEnv),
+		Suffix:    hex.EncodeToString(suffix),
+	}
+	if id.Name == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			id.Name = hostname
+		}
+	}
+	return id, nil
+}
+
+// ParseIdentity parses an owner ID created from an Identity.
+func ParseIdentity(ownerID string) (Identity, error) {
+	parts := strings.Split(ownerID, identitySeparator)
+	if len(parts) != identityParts {
+		return Identity{}, maskAny(fmt.Errorf("owner ID '%s' is not an identity", ownerID))
+	}
+	return Identity{
+		Namespace: parts[0],
+		Name:      parts[1],
+		UID:       parts[2],
+		Node:      parts[3],
+		Suffix:    parts[4],
+	}, nil
+}
+
+// String returns the owner ID for the identity.
+func (id Identity) String() string {
+	return strings.Join([]string{id.Namespace, id.Name, id.UID, id.Node, id.Suffix}, identitySeparator)
+}
+
+// NewOwnerID creates an owner ID for the current process.
+// See NewIdentity.
+func NewOwnerID() (string, error) {
+	id, err := NewIdentity()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return id.String(), nil
+}
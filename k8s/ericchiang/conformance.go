@@ -0,0 +1,182 @@
+package ericchiang
+
+import (
+	"context"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/ericchiang/k8s/apis/core/v1"
+	"github.com/ericchiang/k8s/apis/extensions/v1beta1"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// NewConformanceBackend creates a locktest.Backend that stores its objects as resources
+// of given kind in the given namespace. For cluster scoped kinds, such as namespaces & nodes,
+// the namespace is ignored.
+// Workloads are created with a node selector that matches no node, so no pods are started.
+func NewConformanceBackend(kind Kind, namespace string, c *kc.Client) (locktest.Backend, error) {
+	if _, err := ParseKind(string(kind)); err != nil {
+		return nil, maskAny(err)
+	}
+	if kind.IsClusterScoped() {
+		namespace = ""
+	}
+	return &conformanceBackend{
+		kind:      kind,
+		namespace: namespace,
+		c:         c,
+	}, nil
+}
+
+type conformanceBackend struct {
+	kind      Kind
+	namespace string
+	c         *kc.Client
+}
+
+const (
+	// conformanceNodeSelector is a node selector label that no node has.
+	conformanceNodeSelector = "pulcy.com/kube-lock-conformance"
+)
+
+// Create creates a resource with given name holding the given annotations.
+func (b *conformanceBackend) Create(name string, annotations map[string]string) error {
+	ctx := context.Background()
+	if err := b.c.Create(ctx, b.newResource(name, annotations)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Delete removes the resource with given name.
+func (b *conformanceBackend) Delete(name string) error {
+	ctx := context.Background()
+	if err := b.c.Delete(ctx, b.newResource(name, nil)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Meta returns the get & update functions for the resource with given name.
+func (b *conformanceBackend) Meta(name string) (lock.MetaGetter, lock.MetaUpdater) {
+	// The kind has been checked by NewConformanceBackend
+	get, update, _ := NewMeta(b.kind, b.namespace, name, b.c)
+	return get, update
+}
+
+// newResource creates a resource with given name & annotations of the kind of the backend.
+func (b *conformanceBackend) newResource(name string, annotations map[string]string) kc.Resource {
+	md := &metav1.ObjectMeta{
+		Name:        kc.String(name),
+		Annotations: annotations,
+	}
+	if b.namespace != "" {
+		md.Namespace = kc.String(b.namespace)
+	}
+	labels := map[string]string{"app": name}
+	podSpec := &v1.PodSpec{
+		Containers: []*v1.Container{
+			{Name: kc.String("pause"), Image: kc.String("k8s.gcr.io/pause:3.1")},
+		},
+		NodeSelector: map[string]string{conformanceNodeSelector: "none"},
+	}
+	template := &v1.PodTemplateSpec{
+		Metadata: &metav1.ObjectMeta{Labels: labels},
+		Spec:     podSpec,
+	}
+	selector := &metav1.LabelSelector{MatchLabels: labels}
+	switch b.kind {
+	case KindDaemonSet:
+		return &v1beta1.DaemonSet{
+			Metadata: md,
+			Spec:     &v1beta1.DaemonSetSpec{Selector: selector, Template: template},
+		}
+	case KindDeployment:
+		return &v1beta1.Deployment{
+			Metadata: md,
+			Spec:     &v1beta1.DeploymentSpec{Replicas: kc.Int32(0), Selector: selector, Template: template},
+		}
+	case KindReplicaSet:
+		return &v1beta1.ReplicaSet{
+			Metadata: md,
+			Spec:     &v1beta1.ReplicaSetSpec{Replicas: kc.Int32(0), Selector: selector, Template: template},
+		}
+	case KindNamespace:
+		return &v1.Namespace{Metadata: md}
+	case KindConfigMap:
+		return &v1.ConfigMap{Metadata: md}
+	case KindEndpoints:
+		return &v1.Endpoints{Metadata: md}
+	case KindNode:
+		return &v1.Node{Metadata: md}
+	case KindPod:
+		return &v1.Pod{Metadata: md, Spec: podSpec}
+	default:
+		// KindService
+		return &v1.Service{
+			Metadata: md,
+			Spec: &v1.ServiceSpec{
+				Ports: []*v1.ServicePort{
+					{Name: kc.String("dummy"), Port: kc.Int32(80)},
+				},
+			},
+		}
+	}
+}
+
+// NewLeaseConformanceBackend creates a locktest.Backend that stores its objects as Leases
+// in the given namespace, mirroring the lock with the default annotation key into their spec.
+func NewLeaseConformanceBackend(namespace string, c *kc.Client) locktest.Backend {
+	return &leaseConformanceBackend{
+		namespace: namespace,
+		c:         c,
+	}
+}
+
+type leaseConformanceBackend struct {
+	namespace string
+	c         *kc.Client
+}
+
+// Create creates a Lease with given name holding the given annotations.
+func (b *leaseConformanceBackend) Create(name string, annotations map[string]string) error {
+	lease := &Lease{
+		Metadata: &metav1.ObjectMeta{
+			Name:        kc.String(name),
+			Namespace:   kc.String(b.namespace),
+			Annotations: annotations,
+		},
+		Spec: &LeaseSpec{},
+	}
+	ctx := context.Background()
+	if err := b.c.Create(ctx, lease); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Delete removes the Lease with given name.
+func (b *leaseConformanceBackend) Delete(name string) error {
+	lease := &Lease{
+		Metadata: &metav1.ObjectMeta{
+			Name:      kc.String(name),
+			Namespace: kc.String(b.namespace),
+		},
+	}
+	ctx := context.Background()
+	if err := b.c.Delete(ctx, lease); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Meta returns the get & update functions for the Lease with given name.
+func (b *leaseConformanceBackend) Meta(name string) (lock.MetaGetter, lock.MetaUpdater) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: b.namespace,
+		c:         b.c,
+	}
+	return lock.NewLeaseMeta("", helper.leaseGet, helper.leaseUpdate)
+}
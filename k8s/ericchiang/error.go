@@ -0,0 +1,15 @@
+package ericchiang
+
+import (
+	"net/http"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/juju/errgo"
+)
+
+// IsConflict returns true if the given error is caused by an update that was rejected
+// because the resource has been changed by someone else.
+func IsConflict(err error) bool {
+	apiErr, ok := errgo.Cause(err).(*kc.APIError)
+	return ok && apiErr.Code == http.StatusConflict
+}
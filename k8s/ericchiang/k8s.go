@@ -68,6 +68,36 @@ func NewServiceLock(namespace, name string, c *kc.Client, annotationKey, ownerID
 	return l, nil
 }
 
+// NewConfigMapLock creates a lock that uses a ConfigMap to hold the lock data.
+func NewConfigMapLock(namespace, name string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.configMapGet, helper.configMapUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+// NewEndpointsLock creates a lock that uses an Endpoints resource to hold the lock data.
+// Note that the endpoints controller manages the Endpoints of a Service with a selector,
+// so use Endpoints without a matching Service, or with a Service without a selector.
+func NewEndpointsLock(namespace, name string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.endpointsGet, helper.endpointsUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
 // NewNamespaceLock creates a lock that uses a Namespace to hold the lock data.
 func NewNamespaceLock(namespace string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration) (lock.KubeLock, error) {
 	helper := &k8sHelper{
@@ -82,6 +112,36 @@ func NewNamespaceLock(namespace string, c *kc.Client, annotationKey, ownerID str
 	return l, nil
 }
 
+// NewNodeLock creates a lock that uses a Node to hold the lock data,
+// e.g. to serialize maintenance among the agents running on that node.
+func NewNodeLock(node string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      node,
+		namespace: "",
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.nodeGet, helper.nodeUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+// NewPodLock creates a lock that uses a Pod to hold the lock data.
+// The lock data is lost when the Pod is deleted.
+func NewPodLock(namespace, name string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.podGet, helper.podUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
 type k8sHelper struct {
 	name      string
 	namespace string
@@ -216,3 +276,103 @@ func (h *k8sHelper) namespaceUpdate(annotations map[string]string, resourceVersi
 	}
 	return nil
 }
+
+func (h *k8sHelper) configMapGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	var configMap v1.ConfigMap
+	ctx := context.Background()
+	if err := h.c.Get(ctx, h.namespace, h.name, &configMap); err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	md := configMap.GetMetadata()
+	return md.GetAnnotations(), md.GetResourceVersion(), &configMap, nil
+}
+
+func (h *k8sHelper) configMapUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	configMap, ok := extra.(*v1.ConfigMap)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *ConfigMap"))
+	}
+	md := configMap.GetMetadata()
+	md.Annotations = annotations
+	md.ResourceVersion = kc.String(resourceVersion)
+	ctx := context.Background()
+	if err := h.c.Update(ctx, configMap); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (h *k8sHelper) endpointsGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	var endpoints v1.Endpoints
+	ctx := context.Background()
+	if err := h.c.Get(ctx, h.namespace, h.name, &endpoints); err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	md := endpoints.GetMetadata()
+	return md.GetAnnotations(), md.GetResourceVersion(), &endpoints, nil
+}
+
+func (h *k8sHelper) endpointsUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	endpoints, ok := extra.(*v1.Endpoints)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Endpoints"))
+	}
+	md := endpoints.GetMetadata()
+	md.Annotations = annotations
+	md.ResourceVersion = kc.String(resourceVersion)
+	ctx := context.Background()
+	if err := h.c.Update(ctx, endpoints); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (h *k8sHelper) nodeGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	var node v1.Node
+	ctx := context.Background()
+	if err := h.c.Get(ctx, h.namespace, h.name, &node); err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	md := node.GetMetadata()
+	return md.GetAnnotations(), md.GetResourceVersion(), &node, nil
+}
+
+func (h *k8sHelper) nodeUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	node, ok := extra.(*v1.Node)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Node"))
+	}
+	md := node.GetMetadata()
+	md.Annotations = annotations
+	md.ResourceVersion = kc.String(resourceVersion)
+	ctx := context.Background()
+	if err := h.c.Update(ctx, node); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (h *k8sHelper) podGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	var pod v1.Pod
+	ctx := context.Background()
+	if err := h.c.Get(ctx, h.namespace, h.name, &pod); err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	md := pod.GetMetadata()
+	return md.GetAnnotations(), md.GetResourceVersion(), &pod, nil
+}
+
+func (h *k8sHelper) podUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	pod, ok := extra.(*v1.Pod)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Pod"))
+	}
+	md := pod.GetMetadata()
+	md.Annotations = annotations
+	md.ResourceVersion = kc.String(resourceVersion)
+	ctx := context.Background()
+	if err := h.c.Update(ctx, pod); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
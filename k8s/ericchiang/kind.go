@@ -0,0 +1,190 @@
+package ericchiang
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/ericchiang/k8s/apis/core/v1"
+	"github.com/ericchiang/k8s/apis/extensions/v1beta1"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// Kind identifies a kind of resource that can hold lock data.
+type Kind string
+
+const (
+	KindDaemonSet  Kind = "daemonset"
+	KindDeployment Kind = "deployment"
+	KindReplicaSet Kind = "replicaset"
+	KindService    Kind = "service"
+	KindNamespace  Kind = "namespace"
+	KindConfigMap  Kind = "configmap"
+	KindEndpoints  Kind = "endpoints"
+	KindNode       Kind = "node"
+	KindPod        Kind = "pod"
+)
+
+// Kinds contains all supported kinds of resources.
+var Kinds = []Kind{
+	KindDaemonSet,
+	KindDeployment,
+	KindReplicaSet,
+	KindService,
+	KindNamespace,
+	KindConfigMap,
+	KindEndpoints,
+	KindNode,
+	KindPod,
+}
+
+// ParseKind parses the given kind name (case insensitive).
+func ParseKind(name string) (Kind, error) {
+	kind := Kind(strings.ToLower(name))
+	for _, k := range Kinds {
+		if k == kind {
+			return kind, nil
+		}
+	}
+	return "", maskAny(fmt.Errorf("unknown kind '%s'", name))
+}
+
+// IsClusterScoped returns true if resources of this kind do not belong to a namespace.
+func (k Kind) IsClusterScoped() bool {
+	return k == KindNamespace || k == KindNode
+}
+
+// NewMeta returns the get & update functions for the resource of given kind, namespace & name.
+// For cluster scoped kinds, such as namespaces & nodes, the namespace is ignored.
+func NewMeta(kind Kind, namespace, name string, c *kc.Client) (lock.MetaGetter, lock.MetaUpdater, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	switch kind {
+	case KindDaemonSet:
+		return helper.daemonSetGet, helper.daemonSetUpdate, nil
+	case KindDeployment:
+		return helper.deploymentGet, helper.deploymentUpdate, nil
+	case KindReplicaSet:
+		return helper.replicaSetGet, helper.replicaSetUpdate, nil
+	case KindService:
+		return helper.serviceGet, helper.serviceUpdate, nil
+	case KindNamespace:
+		helper.namespace = ""
+		return helper.namespaceGet, helper.namespaceUpdate, nil
+	case KindConfigMap:
+		return helper.configMapGet, helper.configMapUpdate, nil
+	case KindEndpoints:
+		return helper.endpointsGet, helper.endpointsUpdate, nil
+	case KindNode:
+		helper.namespace = ""
+		return helper.nodeGet, helper.nodeUpdate, nil
+	case KindPod:
+		return helper.podGet, helper.podUpdate, nil
+	default:
+		return nil, nil, maskAny(fmt.Errorf("unknown kind '%s'", kind))
+	}
+}
+
+// NewLock creates a lock that uses the resource of given kind, namespace & name to hold the lock data.
+// For cluster scoped kinds, such as namespaces & nodes, the namespace is ignored.
+func NewLock(kind Kind, namespace, name string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	get, update, err := NewMeta(kind, namespace, name, c)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, get, update, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+// List returns the metadata of all resources of given kind in the given namespace.
+// For cluster scoped kinds, such as namespaces & nodes, the namespace is ignored.
+func List(kind Kind, namespace string, c *kc.Client) ([]*metav1.ObjectMeta, error) {
+	ctx := context.Background()
+	var result []*metav1.ObjectMeta
+	switch kind {
+	case KindDaemonSet:
+		var list v1beta1.DaemonSetList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindDeployment:
+		var list v1beta1.DeploymentList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindReplicaSet:
+		var list v1beta1.ReplicaSetList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindService:
+		var list v1.ServiceList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindNamespace:
+		var list v1.NamespaceList
+		if err := c.List(ctx, "", &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindConfigMap:
+		var list v1.ConfigMapList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindEndpoints:
+		var list v1.EndpointsList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindNode:
+		var list v1.NodeList
+		if err := c.List(ctx, "", &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	case KindPod:
+		var list v1.PodList
+		if err := c.List(ctx, namespace, &list); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, item := range list.GetItems() {
+			result = append(result, item.GetMetadata())
+		}
+	default:
+		return nil, maskAny(fmt.Errorf("unknown kind '%s'", kind))
+	}
+	return result, nil
+}
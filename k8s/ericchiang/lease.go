@@ -0,0 +1,139 @@
+package ericchiang
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kc "github.com/ericchiang/k8s"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// Lease is a coordination.k8s.io/v1 Lease.
+type Lease struct {
+	Metadata *metav1.ObjectMeta `json:"metadata"`
+	Spec     *LeaseSpec         `json:"spec"`
+}
+
+// LeaseSpec is the spec of a Lease.
+// Times are MicroTime values (RFC3339 with microseconds).
+type LeaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32  `json:"leaseTransitions,omitempty"`
+}
+
+// GetMetadata returns the metadata of the Lease.
+func (l *Lease) GetMetadata() *metav1.ObjectMeta {
+	return l.Metadata
+}
+
+// LeaseList is a list of Leases.
+type LeaseList struct {
+	Metadata *metav1.ListMeta `json:"metadata"`
+	Items    []*Lease         `json:"items"`
+}
+
+// GetMetadata returns the metadata of the list.
+func (l *LeaseList) GetMetadata() *metav1.ListMeta {
+	return l.Metadata
+}
+
+func init() {
+	kc.Register("coordination.k8s.io", "v1", "leases", true, &Lease{})
+	kc.RegisterList("coordination.k8s.io", "v1", "leases", true, &LeaseList{})
+}
+
+const (
+	// microTimeFormat is the format of a MicroTime in the Kubernetes API.
+	microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// NewLeaseLock creates a lock that uses a coordination.k8s.io/v1 Lease to hold the lock.
+// The holder & expiration of the lock are stored in the spec of the Lease, the lock data in
+// its annotation with given key.
+func NewLeaseLock(namespace, name string, c *kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	get, update := lock.NewLeaseMeta(annotationKey, helper.leaseGet, helper.leaseUpdate)
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, get, update, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+func (h *k8sHelper) leaseGet() (record lock.LeaseRecord, annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	var lease Lease
+	ctx := context.Background()
+	if err := h.c.Get(ctx, h.namespace, h.name, &lease); err != nil {
+		return lock.LeaseRecord{}, nil, "", nil, maskAny(err)
+	}
+	if spec := lease.Spec; spec != nil {
+		if spec.HolderIdentity != nil {
+			record.HolderIdentity = *spec.HolderIdentity
+		}
+		if spec.LeaseDurationSeconds != nil {
+			record.LeaseDurationSeconds = *spec.LeaseDurationSeconds
+		}
+		if spec.LeaseTransitions != nil {
+			record.LeaseTransitions = *spec.LeaseTransitions
+		}
+		if record.AcquireTime, err = parseMicroTime(spec.AcquireTime); err != nil {
+			return lock.LeaseRecord{}, nil, "", nil, maskAny(err)
+		}
+		if record.RenewTime, err = parseMicroTime(spec.RenewTime); err != nil {
+			return lock.LeaseRecord{}, nil, "", nil, maskAny(err)
+		}
+	}
+	md := lease.GetMetadata()
+	return record, md.GetAnnotations(), md.GetResourceVersion(), &lease, nil
+}
+
+func (h *k8sHelper) leaseUpdate(record lock.LeaseRecord, annotations map[string]string, resourceVersion string, extra interface{}) error {
+	lease, ok := extra.(*Lease)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Lease"))
+	}
+	lease.Spec = &LeaseSpec{
+		HolderIdentity:       kc.String(record.HolderIdentity),
+		LeaseDurationSeconds: kc.Int32(record.LeaseDurationSeconds),
+		AcquireTime:          formatMicroTime(record.AcquireTime),
+		RenewTime:            formatMicroTime(record.RenewTime),
+		LeaseTransitions:     kc.Int32(record.LeaseTransitions),
+	}
+	md := lease.GetMetadata()
+	md.Annotations = annotations
+	md.ResourceVersion = kc.String(resourceVersion)
+	ctx := context.Background()
+	if err := h.c.Update(ctx, lease); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// parseMicroTime parses the given MicroTime, returning a zero time for nil.
+func parseMicroTime(s *string) (time.Time, error) {
+	if s == nil || *s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *s)
+	if err != nil {
+		return time.Time{}, maskAny(err)
+	}
+	return t, nil
+}
+
+// formatMicroTime formats the given time as MicroTime, returning nil for a zero time.
+func formatMicroTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	return kc.String(t.UTC().Format(microTimeFormat))
+}
@@ -0,0 +1,23 @@
+package ericchiang
+
+import (
+	"context"
+	"fmt"
+
+	kc "github.com/ericchiang/k8s"
+	"github.com/ericchiang/k8s/apis/core/v1"
+)
+
+// LoadSigningKey loads a key for lock.WithSigningKey from the given data key of a Secret.
+func LoadSigningKey(namespace, name, key string, c *kc.Client) ([]byte, error) {
+	var secret v1.Secret
+	ctx := context.Background()
+	if err := c.Get(ctx, namespace, name, &secret); err != nil {
+		return nil, maskAny(err)
+	}
+	data, ok := secret.GetData()[key]
+	if !ok || len(data) == 0 {
+		return nil, maskAny(fmt.Errorf("secret %s/%s has no data for key %s", namespace, name, key))
+	}
+	return data, nil
+}
@@ -0,0 +1,145 @@
+package yaklabs
+
+import (
+	"fmt"
+
+	kc "github.com/YakLabs/k8s-client"
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// ConformanceKinds contains the kinds of resources supported by NewConformanceBackend.
+var ConformanceKinds = []string{"daemonset", "replicaset", "service", "node", "pod"}
+
+// NewConformanceBackend creates a locktest.Backend that stores its objects as resources
+// of given kind (see ConformanceKinds) in the given namespace.
+// For nodes the namespace is ignored.
+// Workloads are created with a node selector that matches no node, so no pods are started.
+func NewConformanceBackend(kind, namespace string, c kc.Client) (locktest.Backend, error) {
+	switch kind {
+	case "daemonset", "replicaset", "service", "pod":
+	case "node":
+		namespace = ""
+	default:
+		return nil, maskAny(fmt.Errorf("unknown kind '%s'", kind))
+	}
+	return &conformanceBackend{
+		kind:      kind,
+		namespace: namespace,
+		c:         c,
+	}, nil
+}
+
+type conformanceBackend struct {
+	kind      string
+	namespace string
+	c         kc.Client
+}
+
+const (
+	// conformanceNodeSelector is a node selector label that no node has.
+	conformanceNodeSelector = "pulcy.com/kube-lock-conformance"
+)
+
+// Create creates a resource with given name holding the given annotations.
+func (b *conformanceBackend) Create(name string, annotations map[string]string) error {
+	md := kc.ObjectMeta{
+		Name:        name,
+		Namespace:   b.namespace,
+		Annotations: annotations,
+	}
+	labels := map[string]string{"app": name}
+	podSpec := &kc.PodSpec{
+		Containers: []kc.Container{
+			{Name: "pause", Image: "k8s.gcr.io/pause:3.1"},
+		},
+		NodeSelector: map[string]string{conformanceNodeSelector: "none"},
+	}
+	template := kc.PodTemplateSpec{
+		ObjectMeta: kc.ObjectMeta{Labels: labels},
+		Spec:       podSpec,
+	}
+	selector := &kc.LabelSelector{MatchLabels: labels}
+	var err error
+	switch b.kind {
+	case "daemonset":
+		_, err = b.c.CreateDaemonSet(b.namespace, &kc.DaemonSet{
+			TypeMeta:   kc.TypeMeta{Kind: "DaemonSet", APIVersion: "extensions/v1beta1"},
+			ObjectMeta: md,
+			Spec:       &kc.DaemonSetSpec{Selector: selector, Template: template},
+		})
+	case "replicaset":
+		_, err = b.c.CreateReplicaSet(b.namespace, &kc.ReplicaSet{
+			TypeMeta:   kc.TypeMeta{Kind: "ReplicaSet", APIVersion: "extensions/v1beta1"},
+			ObjectMeta: md,
+			Spec:       &kc.ReplicaSetSpec{Selector: selector, Template: template},
+		})
+	case "node":
+		_, err = b.c.CreateNode(&kc.Node{
+			TypeMeta:   kc.TypeMeta{Kind: "Node", APIVersion: "v1"},
+			ObjectMeta: md,
+		})
+	case "pod":
+		_, err = b.c.CreatePod(b.namespace, &kc.Pod{
+			TypeMeta:   kc.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: md,
+			Spec:       podSpec,
+		})
+	default:
+		_, err = b.c.CreateService(b.namespace, &kc.Service{
+			TypeMeta:   kc.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: md,
+			Spec: &kc.ServiceSpec{
+				Ports: []kc.ServicePort{
+					{Name: "dummy", Port: 80},
+				},
+			},
+		})
+	}
+	if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Delete removes the resource with given name.
+func (b *conformanceBackend) Delete(name string) error {
+	var err error
+	switch b.kind {
+	case "daemonset":
+		err = b.c.DeleteDaemonSet(b.namespace, name)
+	case "replicaset":
+		err = b.c.DeleteReplicaSet(b.namespace, name)
+	case "node":
+		err = b.c.DeleteNode(name)
+	case "pod":
+		err = b.c.DeletePod(b.namespace, name)
+	default:
+		err = b.c.DeleteService(b.namespace, name)
+	}
+	if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Meta returns the get & update functions for the resource with given name.
+func (b *conformanceBackend) Meta(name string) (lock.MetaGetter, lock.MetaUpdater) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: b.namespace,
+		c:         b.c,
+	}
+	switch b.kind {
+	case "daemonset":
+		return helper.daemonSetGet, helper.daemonSetUpdate
+	case "replicaset":
+		return helper.replicaSetGet, helper.replicaSetUpdate
+	case "node":
+		return helper.nodeGet, helper.nodeUpdate
+	case "pod":
+		return helper.podGet, helper.podUpdate
+	default:
+		return helper.serviceGet, helper.serviceUpdate
+	}
+}
@@ -51,6 +51,36 @@ func NewServiceLock(namespace, name string, c kc.Client, annotationKey, ownerID
 	return l, nil
 }
 
+// NewNodeLock creates a lock that uses a Node to hold the lock data,
+// e.g. to serialize maintenance among the agents running on that node.
+func NewNodeLock(node string, c kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      node,
+		namespace: "",
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.nodeGet, helper.nodeUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+// NewPodLock creates a lock that uses a Pod to hold the lock data.
+// The lock data is lost when the Pod is deleted.
+func NewPodLock(namespace, name string, c kc.Client, annotationKey, ownerID string, ttl time.Duration, options ...lock.Option) (lock.ExtendedLock, error) {
+	helper := &k8sHelper{
+		name:      name,
+		namespace: namespace,
+		c:         c,
+	}
+	l, err := lock.NewKubeLock(annotationKey, ownerID, ttl, helper.podGet, helper.podUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
 type k8sHelper struct {
 	name      string
 	namespace string
@@ -123,3 +153,45 @@ func (h *k8sHelper) serviceUpdate(annotations map[string]string, resourceVersion
 	}
 	return nil
 }
+
+func (h *k8sHelper) nodeGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	node, err := h.c.GetNode(h.name)
+	if err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	return node.ObjectMeta.Annotations, node.ObjectMeta.ResourceVersion, node, nil
+}
+
+func (h *k8sHelper) nodeUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	node, ok := extra.(*kc.Node)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Node"))
+	}
+	node.ObjectMeta.Annotations = annotations
+	node.ObjectMeta.ResourceVersion = resourceVersion
+	if _, err := h.c.UpdateNode(node); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (h *k8sHelper) podGet() (annotations map[string]string, resourceVersion string, extra interface{}, err error) {
+	pod, err := h.c.GetPod(h.namespace, h.name)
+	if err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	return pod.ObjectMeta.Annotations, pod.ObjectMeta.ResourceVersion, pod, nil
+}
+
+func (h *k8sHelper) podUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	pod, ok := extra.(*kc.Pod)
+	if !ok {
+		return maskAny(fmt.Errorf("extra must be *Pod"))
+	}
+	pod.ObjectMeta.Annotations = annotations
+	pod.ObjectMeta.ResourceVersion = resourceVersion
+	if _, err := h.c.UpdatePod(h.namespace, pod); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package lock
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+// KeyedLock provides many independent named locks that are all stored in a single resource.
+// Every named lock is stored in its own annotation, the key of which is formed from
+// an annotation prefix and the name of the lock.
+// All locks rely on the resource version of the resource for compare-and-swap.
+type KeyedLock interface {
+	// Acquire tries to acquire the lock with given key.
+	// If the lock is already held by us, the lock will be updated.
+	// If successfull it returns nil, otherwise it returns an error.
+	Acquire(key string) error
+
+	// Release tries to release the lock with given key.
+	// If the lock is already held by us, the lock will be released.
+	// If successfull it returns nil, otherwise it returns an error.
+	Release(key string) error
+
+	// Owner fetches the current owner ID of the lock with given key.
+	// If the lock is not owned, "" is returned.
+	Owner(key string) (string, error)
+
+	// Owners fetches the current owner ID of all owned locks, using a single get.
+	// The result maps the key of a lock to its owner ID.
+	Owners() (map[string]string, error)
+
+	// Keys fetches the keys of all locks that have a record in the resource, using a single get.
+	// The result is sorted.
+	Keys() ([]string, error)
+
+	// Remove deletes the record of the lock with given key, and its history, from the resource.
+	// If the lock is held or frozen, the record is kept and an error is returned.
+	Remove(key string) error
+
+	// Lock returns the lock for the given key.
+	Lock(key string) (ExtendedLock, error)
+}
+
+// NewKeyedLock creates a new KeyedLock.
+// The lock with key 'k' is stored in an annotation with key '<annotationPrefix>.k'.
+// If ownerID is empty, an owner ID is created using NewOwnerID.
+// None of the locks will be acquired.
+func NewKeyedLock(annotationPrefix, ownerID string, ttl time.Duration, metaGet MetaGetter, metaUpdate MetaUpdater, options ...Option) (KeyedLock, error) {
+	if annotationPrefix == "" {
+		annotationPrefix = defaultAnnotationKey
+	}
+	if ownerID == "" {
+		id, err := NewOwnerID()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		ownerID = id
+	}
+	if metaGet == nil {
+		return nil, maskAny(fmt.Errorf("metaGet cannot be nil"))
+	}
+	if metaUpdate == nil {
+		return nil, maskAny(fmt.Errorf("metaUpdate cannot be nil"))
+	}
+	return &keyedLock{
+		annotationPrefix: annotationPrefix,
+		ownerID:          ownerID,
+		ttl:              ttl,
+		getMeta:          metaGet,
+		updateMeta:       metaUpdate,
+		options:          options,
+		locks:            make(map[string]ExtendedLock),
+	}, nil
+}
+
+const (
+	// maxAnnotationNameLength is the maximum length of the name part of an annotation key.
+	maxAnnotationNameLength = 63
+)
+
+var (
+	keyPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+)
+
+type keyedLock struct {
+	annotationPrefix string
+	ownerID          string
+	ttl              time.Duration
+	getMeta          MetaGetter
+	updateMeta       MetaUpdater
+	options          []Option
+
+	mutex sync.Mutex
+	locks map[string]ExtendedLock
+}
+
+// Acquire tries to acquire the lock with given key.
+func (kl *keyedLock) Acquire(key string) error {
+	l, err := kl.Lock(key)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := l.Acquire(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Release tries to release the lock with given key.
+func (kl *keyedLock) Release(key string) error {
+	l, err := kl.Lock(key)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := l.Release(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Owner fetches the current owner ID of the lock with given key.
+func (kl *keyedLock) Owner(key string) (string, error) {
+	l, err := kl.Lock(key)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	owner, err := l.CurrentOwner()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return owner, nil
+}
+
+// Owners fetches the current owner ID of all owned locks, using a single get.
+func (kl *keyedLock) Owners() (map[string]string, error) {
+	ann, _, _, err := kl.getMeta()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]string)
+	for _, key := range kl.keys(ann) {
+		l, err := kl.Lock(key)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		lockData, found, err := l.impl().readLockData(ann)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		if found && lockData.Owner != "" {
+			result[key] = lockData.Owner
+		}
+	}
+	return result, nil
+}
+
+// Keys fetches the keys of all locks that have a record in the resource, using a single get.
+func (kl *keyedLock) Keys() ([]string, error) {
+	ann, _, _, err := kl.getMeta()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	keys := kl.keys(ann)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// keys returns the keys of all locks that have a record in the given annotations.
+func (kl *keyedLock) keys(ann map[string]string) []string {
+	var result []string
+	prefix := kl.annotationPrefix + "."
+	for annKey := range ann {
+		if !strings.HasPrefix(annKey, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(annKey, prefix)
+		if base := strings.TrimSuffix(key, HistorySuffix); base != key {
+			if bl, err := kl.Lock(base); err == nil && bl.impl().historyKey == annKey {
+				// History of another lock
+				continue
+			}
+		}
+		if _, err := kl.Lock(key); err != nil {
+			// Not a lock created by us
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// Remove deletes the record of the lock with given key, and its history, from the resource.
+func (kl *keyedLock) Remove(key string) error {
+	el, err := kl.Lock(key)
+	if err != nil {
+		return maskAny(err)
+	}
+	l := el.impl()
+
+	// Get current state
+	ann, rv, extra, err := l.getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, found := ann[l.annotationKey]; !found {
+		return nil
+	}
+	lockData, _, err := l.readLockData(ann)
+	if err != nil {
+		return maskAny(err)
+	}
+	if lockData.Frozen {
+		return maskAny(errgo.WithCausef(nil, LockFrozenError, "frozen: %s", lockData.Reason))
+	}
+	end, err := l.holdEnd(ann, lockData)
+	if err != nil {
+		return maskAny(err)
+	}
+	if l.clock.Now().Before(end) {
+		return maskAny(errgo.WithCausef(nil, AlreadyLockedError, "locked by %s", lockData.Owner))
+	}
+
+	// Remove the record
+	delete(ann, l.annotationKey)
+	if l.historyKey != "" {
+		delete(ann, l.historyKey)
+	}
+	if err := l.updateMeta(ann, rv, extra); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Lock returns the lock for the given key.
+func (kl *keyedLock) Lock(key string) (ExtendedLock, error) {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	if l, found := kl.locks[key]; found {
+		return l, nil
+	}
+	annotationKey, err := kl.annotationKey(key)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	l, err := NewKubeLock(annotationKey, kl.ownerID, kl.ttl, kl.getMeta, kl.updateMeta, kl.options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	kl.locks[key] = l
+	return l, nil
+}
+
+// annotationKey returns the key of the annotation that stores the lock with given key.
+func (kl *keyedLock) annotationKey(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", maskAny(fmt.Errorf("invalid key '%s'", key))
+	}
+	annotationKey := kl.annotationPrefix + "." + key
+	name := annotationKey
+	if i := strings.LastIndex(annotationKey, "/"); i >= 0 {
+		name = annotationKey[i+1:]
+	}
+	if len(name) > maxAnnotationNameLength {
+		return "", maskAny(fmt.Errorf("key '%s' is too long", key))
+	}
+	return annotationKey, nil
+}
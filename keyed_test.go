@@ -0,0 +1,109 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// TestKeyedLockPerKey checks that locks with different keys in the same resource
+// are acquired, released & owned independently.
+func TestKeyedLockPerKey(t *testing.T) {
+	get, update := newTestObject(t)
+	a, err := lock.NewKeyedLock("", "a", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	b, err := lock.NewKeyedLock("", "b", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	if err := a.Acquire("x"); err != nil {
+		t.Fatalf("Acquire x failed: %v", err)
+	}
+	if err := b.Acquire("y"); err != nil {
+		t.Fatalf("Acquire y failed: %v", err)
+	}
+	if err := b.Acquire("x"); !lock.IsAlreadyLocked(err) {
+		t.Errorf("Expected AlreadyLockedError, got %v", err)
+	}
+	if err := b.Release("x"); !lock.IsNotLockedByMe(err) {
+		t.Errorf("Expected NotLockedByMeError, got %v", err)
+	}
+	if owner, err := b.Owner("x"); err != nil || owner != "a" {
+		t.Errorf("Expected owner 'a' of x, got '%s' (%v)", owner, err)
+	}
+	if owners, err := a.Owners(); err != nil || len(owners) != 2 || owners["x"] != "a" || owners["y"] != "b" {
+		t.Errorf("Expected x owned by a and y owned by b, got %v (%v)", owners, err)
+	}
+
+	if err := a.Release("x"); err != nil {
+		t.Fatalf("Release x failed: %v", err)
+	}
+	if err := b.Acquire("x"); err != nil {
+		t.Errorf("Acquire x after release failed: %v", err)
+	}
+	if owner, err := a.Owner("y"); err != nil || owner != "b" {
+		t.Errorf("Expected owner 'b' of y, got '%s' (%v)", owner, err)
+	}
+}
+
+// TestKeyedLockConflict checks that an update based on an old version of the resource
+// fails, instead of overwriting a lock with another key that was acquired in between.
+func TestKeyedLockConflict(t *testing.T) {
+	get, update := newTestObject(t)
+	a, err := lock.NewKeyedLock("", "a", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	interleaved := false
+	interleavingUpdate := func(annotations map[string]string, resourceVersion string, extra interface{}) error {
+		if !interleaved {
+			interleaved = true
+			if err := a.Acquire("x"); err != nil {
+				t.Fatalf("Acquire x failed: %v", err)
+			}
+		}
+		return update(annotations, resourceVersion, extra)
+	}
+	b, err := lock.NewKeyedLock("", "b", time.Minute, get, interleavingUpdate)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	if err := b.Acquire("y"); err == nil {
+		t.Fatal("Expected Acquire y to conflict")
+	}
+	if err := b.Acquire("y"); err != nil {
+		t.Fatalf("Second Acquire y failed: %v", err)
+	}
+	if owners, err := a.Owners(); err != nil || len(owners) != 2 || owners["x"] != "a" || owners["y"] != "b" {
+		t.Errorf("Expected x owned by a and y owned by b, got %v (%v)", owners, err)
+	}
+}
+
+// TestKeyedLockRemove checks that Remove only deletes records of locks that are not held.
+func TestKeyedLockRemove(t *testing.T) {
+	get, update := newTestObject(t)
+	a, err := lock.NewKeyedLock("", "a", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	for _, key := range []string{"x", "y"} {
+		if err := a.Acquire(key); err != nil {
+			t.Fatalf("Acquire %s failed: %v", key, err)
+		}
+	}
+	if err := a.Release("x"); err != nil {
+		t.Fatalf("Release x failed: %v", err)
+	}
+	if err := a.Remove("y"); !lock.IsAlreadyLocked(err) {
+		t.Errorf("Expected AlreadyLockedError, got %v", err)
+	}
+	if err := a.Remove("x"); err != nil {
+		t.Errorf("Remove x failed: %v", err)
+	}
+	if keys, err := a.Keys(); err != nil || len(keys) != 1 || keys[0] != "y" {
+		t.Errorf("Expected only key y, got %v (%v)", keys, err)
+	}
+}
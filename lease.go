@@ -0,0 +1,125 @@
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LeaseRecord holds the spec of a coordination.k8s.io/v1 Lease.
+type LeaseRecord struct {
+	HolderIdentity       string
+	LeaseDurationSeconds int32
+	AcquireTime          time.Time
+	RenewTime            time.Time
+	LeaseTransitions     int32
+}
+
+// LeaseGetter fetches the spec & annotations of a Lease.
+type LeaseGetter func() (record LeaseRecord, annotations map[string]string, resourceVersion string, extra interface{}, err error)
+
+// LeaseUpdater stores the spec & annotations of a Lease.
+// It must fail when the resource version of the Lease is no longer the given version.
+type LeaseUpdater func(record LeaseRecord, annotations map[string]string, resourceVersion string, extra interface{}) error
+
+// leaseExtra is passed from the getter to the updater created by NewLeaseMeta.
+type leaseExtra struct {
+	record LeaseRecord
+	extra  interface{}
+}
+
+// NewLeaseMeta returns get & update functions for NewKubeLock that store the lock in a Lease.
+// The owner & expiration of the lock are mirrored into the spec of the Lease, so cluster
+// tooling shows the holder natively. The complete lock data (user data, frozen state, signature)
+// is kept in the annotation with given key of the same Lease.
+// Both are written in a single update, using the resource version of the Lease for compare-and-swap.
+//
+// When the Lease is held by a client that does not use kube-lock, the lock data is derived
+// from its spec, so kube-lock respects that holder until the Lease expires.
+// The times in the spec are derived from the lock data, so they use the clock of the lock owner.
+func NewLeaseMeta(annotationKey string, get LeaseGetter, update LeaseUpdater) (MetaGetter, MetaUpdater) {
+	if annotationKey == "" {
+		annotationKey = defaultAnnotationKey
+	}
+	metaGet := func() (map[string]string, string, interface{}, error) {
+		record, ann, rv, extra, err := get()
+		if err != nil {
+			return nil, "", nil, maskAny(err)
+		}
+		if record.HolderIdentity != "" {
+			var lockData LockData
+			if err := json.Unmarshal([]byte(ann[annotationKey]), &lockData); err != nil || lockData.Owner != record.HolderIdentity {
+				// Held by someone else, derive lock data from the spec
+				lockData = LockData{
+					Owner:      record.HolderIdentity,
+					AcquiredAt: record.AcquireTime,
+					ExpiresAt:  record.RenewTime.Add(time.Duration(record.LeaseDurationSeconds) * time.Second),
+				}
+				raw, err := json.Marshal(lockData)
+				if err != nil {
+					return nil, "", nil, maskAny(err)
+				}
+				ann = copyAnnotations(ann)
+				ann[annotationKey] = string(raw)
+			}
+		}
+		return ann, rv, &leaseExtra{record: record, extra: extra}, nil
+	}
+	metaUpdate := func(ann map[string]string, rv string, extra interface{}) error {
+		le, ok := extra.(*leaseExtra)
+		if !ok {
+			return maskAny(fmt.Errorf("extra must be obtained from the lease getter"))
+		}
+		var lockData LockData
+		if raw := ann[annotationKey]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), &lockData); err != nil {
+				return maskAny(err)
+			}
+		}
+		record := le.record
+		end, err := ReadHoldEnd(ann, lockData)
+		if err != nil {
+			return maskAny(err)
+		}
+		if !end.IsZero() {
+			if lockData.Owner != record.HolderIdentity {
+				record.LeaseTransitions++
+				record.LeaseDurationSeconds = 0
+			}
+			if record.LeaseDurationSeconds <= 0 {
+				// New holder, the lock was acquired for the time between acquiring & expiring
+				record.LeaseDurationSeconds = leaseDurationSeconds(end.Sub(lockData.AcquiredAt))
+			}
+			record.HolderIdentity = lockData.Owner
+			record.AcquireTime = lockData.AcquiredAt
+			record.RenewTime = end.Add(-time.Duration(record.LeaseDurationSeconds) * time.Second)
+		} else {
+			// Released (or never held)
+			record.HolderIdentity = ""
+			record.LeaseDurationSeconds = 1
+		}
+		if err := update(record, ann, rv, le.extra); err != nil {
+			return maskAny(err)
+		}
+		return nil
+	}
+	return metaGet, metaUpdate
+}
+
+// leaseDurationSeconds rounds the given duration up to whole seconds, at least 1.
+func leaseDurationSeconds(d time.Duration) int32 {
+	seconds := int32((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// copyAnnotations returns a copy of the given annotations that can safely be changed.
+func copyAnnotations(ann map[string]string) map[string]string {
+	result := make(map[string]string, len(ann)+1)
+	for k, v := range ann {
+		result[k] = v
+	}
+	return result
+}
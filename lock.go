@@ -1,10 +1,10 @@
 package lock
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/juju/errgo"
@@ -30,18 +30,72 @@ type KubeLock interface {
 	CurrentOwner() (string, error)
 }
 
+// ExtendedLock is implemented by all locks created by NewKubeLock & KeyedLock.Lock.
+// It adds features on top of KubeLock, without requiring other implementations
+// of KubeLock to provide them. It cannot be implemented outside this package.
+type ExtendedLock interface {
+	KubeLock
+
+	// AcquireWithTTL tries to acquire the lock for the given ttl instead of the ttl
+	// the lock was created with.
+	// If the lock is already held by us, the lock will be updated.
+	// A Manager keeps renewing the lock with the given ttl, while Acquire & Do
+	// use the ttl the lock was created with.
+	// If successfull it returns nil, otherwise it returns an error.
+	AcquireWithTTL(ttl time.Duration) error
+
+	// IsHeld returns true if the last successful acquire made us the holder of the lock
+	// and that lock will not expire within the safety margin.
+	// A lock bound to a session is held for as long as the session is alive, but never
+	// beyond its maximum hold duration.
+	// IsHeld does not contact the API server.
+	IsHeld() bool
+
+	// WaitUntilFree blocks until the lock is not held by anyone, or the given context is canceled.
+	// A lock that has expired is considered free.
+	WaitUntilFree(ctx context.Context) error
+
+	// WaitForOwnerChange blocks until the owner of the lock is different from the given owner,
+	// or the given context is canceled.
+	// It returns the new owner, which is "" when the lock is free.
+	// A lock that has expired is considered free.
+	WaitForOwnerChange(ctx context.Context, owner string) (string, error)
+
+	// Data fetches the user data stored with the lock.
+	// The user data is kept when the lock is released or changes owner.
+	Data() (map[string]string, error)
+
+	// SetData replaces the user data stored with the lock.
+	// The lock must be held by us.
+	SetData(data map[string]string) error
+
+	// History fetches the ownership history of the lock, oldest entry first.
+	// The history is only recorded when the lock is created with the WithHistory option.
+	History() ([]HistoryEntry, error)
+
+	// State returns a snapshot of the local state of the lock.
+	// State does not contact the API server.
+	State() LockState
+
+	// impl returns the implementation of the lock.
+	// It prevents ExtendedLock from being implemented outside this package,
+	// so Do & Manager can rely on it.
+	impl() *kubeLock
+}
+
 // NewKubeLock creates a new KubeLock.
+// If ownerID is empty, an owner ID is created using NewOwnerID.
 // The lock will not be aquired.
-func NewKubeLock(annotationKey, ownerID string, ttl time.Duration, metaGet MetaGetter, metaUpdate MetaUpdater) (KubeLock, error) {
+func NewKubeLock(annotationKey, ownerID string, ttl time.Duration, metaGet MetaGetter, metaUpdate MetaUpdater, options ...Option) (ExtendedLock, error) {
 	if annotationKey == "" {
 		annotationKey = defaultAnnotationKey
 	}
 	if ownerID == "" {
-		id := make([]byte, 16)
-		if _, err := rand.Read(id); err != nil {
+		id, err := NewOwnerID()
+		if err != nil {
 			return nil, maskAny(err)
 		}
-		ownerID = base64.StdEncoding.EncodeToString(id)
+		ownerID = id
 	}
 	if ttl == 0 {
 		ttl = defaultTTL
@@ -52,13 +106,19 @@ func NewKubeLock(annotationKey, ownerID string, ttl time.Duration, metaGet MetaG
 	if metaUpdate == nil {
 		return nil, maskAny(fmt.Errorf("metaUpdate cannot be nil"))
 	}
-	return &kubeLock{
+	l := &kubeLock{
 		annotationKey: annotationKey,
 		ownerID:       ownerID,
 		ttl:           ttl,
 		getMeta:       metaGet,
 		updateMeta:    metaUpdate,
-	}, nil
+		clock:         realClock{},
+		pollInterval:  defaultPollInterval,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l, nil
 }
 
 const (
@@ -67,94 +127,285 @@ const (
 )
 
 type kubeLock struct {
-	annotationKey string
-	ownerID       string
-	ttl           time.Duration
-	getMeta       MetaGetter
-	updateMeta    MetaUpdater
+	annotationKey   string
+	ownerID         string
+	ttl             time.Duration
+	getMeta         MetaGetter
+	updateMeta      MetaUpdater
+	clock           Clock
+	maxHold         time.Duration
+	safetyMargin    time.Duration
+	signingKey      []byte
+	signaturePolicy SignaturePolicy
+	session         *Session
+	watchMeta       MetaWatcher
+	pollInterval    time.Duration
+	historyKey      string
+	historySize     int
+
+	mutex         sync.Mutex
+	heldUntil     time.Time
+	holdTTL       time.Duration
+	roundTrip     time.Duration
+	lastRoundTrip time.Duration
+	lastAttempt   time.Time
+	lastSuccess   time.Time
+	lastError     error
 }
 
 type LockData struct {
-	Owner     string    `json:"owner"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Owner      string            `json:"owner"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	AcquiredAt time.Time         `json:"acquired_at"`
+	Session    string            `json:"session,omitempty"`
+	Data       map[string]string `json:"data,omitempty"`
+	Frozen     bool              `json:"frozen,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	Signature  string            `json:"signature,omitempty"`
 }
 
 type MetaGetter func() (annotations map[string]string, resourceVersion string, extra interface{}, err error)
 type MetaUpdater func(annotations map[string]string, resourceVersion string, extra interface{}) error
 
+// impl returns the implementation of the lock.
+func (l *kubeLock) impl() *kubeLock {
+	return l
+}
+
 // Acquire tries to acquire the lock.
 // If the lock is already held by us, the lock will be updated.
 // If successfull it returns nil, otherwise it returns an error.
 func (l *kubeLock) Acquire() error {
+	if err := l.AcquireWithTTL(l.ttl); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// AcquireWithTTL tries to acquire the lock for the given ttl instead of the ttl
+// the lock was created with.
+// If the lock is already held by us, the lock will be updated.
+// If successfull it returns nil, otherwise it returns an error.
+func (l *kubeLock) AcquireWithTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return maskAny(fmt.Errorf("ttl must be positive"))
+	}
+
 	// Get current state
+	start := l.clock.Now()
 	ann, rv, extra, err := l.getMeta()
 	if err != nil {
+		l.recordAttempt(start, err)
 		return maskAny(err)
 	}
 
-	// Get lock data
+	// Update lock data
 	if ann == nil {
 		ann = make(map[string]string)
 	}
-	if lockDataRaw, ok := ann[l.annotationKey]; ok && lockDataRaw != "" {
-		var lockData LockData
-		if err := json.Unmarshal([]byte(lockDataRaw), &lockData); err != nil {
-			return maskAny(err)
+	heldUntil, err := l.acquireIn(ann, ttl)
+	if err != nil {
+		if IsAlreadyLocked(err) || IsLockFrozen(err) {
+			l.setHeldUntil(time.Time{})
+		}
+		l.recordAttempt(start, err)
+		return maskAny(err)
+	}
+
+	// Try to lock it now
+	err = l.updateMeta(ann, rv, extra)
+	l.recordRoundTrip(l.clock.Now().Sub(start))
+	l.recordAttempt(start, err)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Update successfull, we've acquired the lock
+	l.setHeldUntil(heldUntil)
+	l.setHoldTTL(ttl)
+	return nil
+}
+
+// acquireIn updates the lock data in the given annotations such that
+// we hold the lock for the given ttl.
+// It returns the time until which we hold the lock.
+func (l *kubeLock) acquireIn(ann map[string]string, ttl time.Duration) (time.Time, error) {
+	now := l.clock.Now()
+	acquiredAt := now
+	var data map[string]string
+	var previous *LockData
+	var previousEnd time.Time
+	if lockData, found, err := l.readLockData(ann); err != nil {
+		return time.Time{}, maskAny(err)
+	} else if found {
+		if lockData.Frozen {
+			// Nobody can acquire a frozen lock
+			return time.Time{}, maskAny(errgo.WithCausef(nil, LockFrozenError, "frozen: %s", lockData.Reason))
+		}
+		// User data is kept, regardless of the owner
+		data = lockData.Data
+		end, err := l.holdEnd(ann, lockData)
+		if err != nil {
+			return time.Time{}, maskAny(err)
 		}
+		held := now.Before(end)
 		if lockData.Owner != l.ownerID {
 			// Lock is owned by someone else
-			if time.Now().Before(lockData.ExpiresAt) {
+			if held {
 				// Lock is held and not expired
-				return maskAny(errgo.WithCausef(nil, AlreadyLockedError, "locked by %s", lockData.Owner))
+				return time.Time{}, maskAny(errgo.WithCausef(nil, AlreadyLockedError, "locked by %s", lockData.Owner))
 			}
+			if lockData.Owner != "" {
+				previous, previousEnd = &lockData, end
+			}
+		} else if l.maxHold > 0 && !lockData.AcquiredAt.IsZero() && !end.Before(lockData.AcquiredAt.Add(l.maxHold)) {
+			// Our hold has been extended up to the maximum hold duration (and may have ended there).
+			// A new hold can only start after we released the lock, or another owner held it.
+			return time.Time{}, maskAny(errgo.WithCausef(nil, MaxHoldExceededError, "held since %s", lockData.AcquiredAt))
+		} else if held {
+			// We're renewing our own lock, our hold continues
+			if !lockData.AcquiredAt.IsZero() {
+				acquiredAt = lockData.AcquiredAt
+			}
+		} else {
+			// Our own lock has expired, a new hold starts
+			previous, previousEnd = &lockData, end
 		}
 	}
 
-	// Try to lock it now
-	expiredAt := time.Now().Add(l.ttl)
-	lockDataRaw, err := json.Marshal(LockData{Owner: l.ownerID, ExpiresAt: expiredAt})
-	if err != nil {
-		return maskAny(err)
+	// Locks bound to a session are held until the session expires.
+	// Their lock data does not get an expiration time, since the session
+	// is renewed without updating the lock data (see currentHeldUntil).
+	expiresAt := now.Add(ttl)
+	heldUntil := expiresAt
+	sessionID := ""
+	if l.session != nil {
+		sessionID = l.session.ID()
+		if _, found := ann[sessionID]; !found {
+			return time.Time{}, maskAny(fmt.Errorf("session %s must be stored in the same resource as the lock", sessionID))
+		}
+		aliveUntil, err := l.session.aliveUntil()
+		if err != nil {
+			return time.Time{}, maskAny(err)
+		}
+		expiresAt, heldUntil = time.Time{}, aliveUntil
 	}
-	ann[l.annotationKey] = string(lockDataRaw)
-	if err := l.updateMeta(ann, rv, extra); err != nil {
-		return maskAny(err)
+
+	// Limit the total hold duration
+	if l.maxHold > 0 {
+		holdEnd := acquiredAt.Add(l.maxHold)
+		if !now.Before(holdEnd) {
+			return time.Time{}, maskAny(errgo.WithCausef(nil, MaxHoldExceededError, "held since %s", acquiredAt))
+		}
+		if expiresAt.IsZero() || expiresAt.After(holdEnd) {
+			expiresAt = holdEnd
+		}
+		if heldUntil.After(holdEnd) || l.session != nil {
+			// Locks bound to a session remember the end of the hold, since their
+			// session can be renewed beyond it
+			heldUntil = holdEnd
+		}
 	}
 
-	// Update successfull, we've acquired the lock
-	return nil
+	// Record the end of the expired hold of the previous owner
+	if previous != nil {
+		endedAt := previousEnd
+		if endedAt.IsZero() || endedAt.After(now) {
+			// Bound to a session that has been closed
+			endedAt = now
+		}
+		if err := l.appendHistory(ann, *previous, endedAt, HistoryReasonExpired); err != nil {
+			return time.Time{}, maskAny(err)
+		}
+	}
+
+	lockData := LockData{Owner: l.ownerID, ExpiresAt: expiresAt, AcquiredAt: acquiredAt, Session: sessionID, Data: data}
+	if err := l.writeLockData(ann, &lockData); err != nil {
+		return time.Time{}, maskAny(err)
+	}
+	return heldUntil, nil
+}
+
+// holdEnd returns the time at which the hold described by the given lock data ends (see LockData.HoldEnd).
+// The liveness record of the session it is bound to is read from the given annotations, and its
+// signature is checked like the signature of our own lock data.
+func (l *kubeLock) holdEnd(ann map[string]string, lockData LockData) (time.Time, error) {
+	var session LockData
+	if lockData.Owner != "" && lockData.Session != "" {
+		s, found, err := l.readLockDataFrom(ann, lockData.Session)
+		if err != nil {
+			return time.Time{}, maskAny(err)
+		} else if found {
+			session = s
+		}
+	}
+	return lockData.HoldEnd(session), nil
+}
+
+// HoldEnd returns the time at which the hold described by the lock data ends.
+// Lock data that is bound to a session is held until the given liveness record of that session,
+// which is stored in the same resource, expires. Pass a zero LockData if that record has been
+// removed. Its own expiration time, if set, limits the hold as well (see WithMaxHoldDuration).
+// A zero time is returned for lock data without owner.
+func (d LockData) HoldEnd(session LockData) time.Time {
+	if d.Owner == "" {
+		return time.Time{}
+	}
+	if d.Session == "" {
+		return d.ExpiresAt
+	}
+	if session.Owner == "" {
+		// Session has been closed
+		return time.Time{}
+	}
+	end := session.ExpiresAt
+	if !d.ExpiresAt.IsZero() && d.ExpiresAt.Before(end) {
+		end = d.ExpiresAt
+	}
+	return end
+}
+
+// ReadHoldEnd returns the time at which the hold described by the given lock data ends
+// (see LockData.HoldEnd). The liveness record of the session it is bound to is read from
+// the given annotations, without checking its signature.
+func ReadHoldEnd(ann map[string]string, lockData LockData) (time.Time, error) {
+	var session LockData
+	if lockData.Owner != "" && lockData.Session != "" {
+		if raw := ann[lockData.Session]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), &session); err != nil {
+				return time.Time{}, maskAny(err)
+			}
+		}
+	}
+	return lockData.HoldEnd(session), nil
 }
 
 // Release tries to release the lock.
 // If the lock is already held by us, the lock will be released.
 // If successfull it returns nil, otherwise it returns an error.
 func (l *kubeLock) Release() error {
+	// We no longer consider ourselves the holder, even if the release fails
+	l.setHeldUntil(time.Time{})
+
 	// Get current state
 	ann, rv, extra, err := l.getMeta()
 	if err != nil {
 		return maskAny(err)
 	}
 
-	// Get lock data
+	// Update lock data
 	if ann == nil {
 		ann = make(map[string]string)
 	}
-	if lockDataRaw, ok := ann[l.annotationKey]; ok && lockDataRaw != "" {
-		var lockData LockData
-		if err := json.Unmarshal([]byte(lockDataRaw), &lockData); err != nil {
-			return maskAny(err)
-		}
-		if lockData.Owner != l.ownerID {
-			// Lock is owned by someone else
-			return maskAny(errgo.WithCausef(nil, NotLockedByMeError, "locked by %s", lockData.Owner))
-		}
-	} else if ok && lockDataRaw == "" {
+	changed, err := l.releaseIn(ann)
+	if err != nil {
+		return maskAny(err)
+	} else if !changed {
 		// Lock is not locked, we consider that a successfull release also.
 		return nil
 	}
 
 	// Try to release lock it now
-	ann[l.annotationKey] = ""
 	if err := l.updateMeta(ann, rv, extra); err != nil {
 		return maskAny(err)
 	}
@@ -163,6 +414,86 @@ func (l *kubeLock) Release() error {
 	return nil
 }
 
+// releaseIn updates the lock data in the given annotations such that
+// we no longer hold the lock.
+// It returns true if the annotations have been changed.
+func (l *kubeLock) releaseIn(ann map[string]string) (bool, error) {
+	if lockData, found, err := l.readLockData(ann); err != nil {
+		return false, maskAny(err)
+	} else if found && lockData.Owner != "" {
+		if lockData.Owner != l.ownerID {
+			// Lock is owned by someone else
+			return false, maskAny(errgo.WithCausef(nil, NotLockedByMeError, "locked by %s", lockData.Owner))
+		}
+		if err := l.appendHistory(ann, lockData, l.clock.Now(), HistoryReasonReleased); err != nil {
+			return false, maskAny(err)
+		}
+		if len(lockData.Data) > 0 || lockData.Frozen {
+			// Keep the user data & frozen state in a record without owner
+			free := LockData{Data: lockData.Data, Frozen: lockData.Frozen, Reason: lockData.Reason}
+			if err := l.writeLockData(ann, &free); err != nil {
+				return false, maskAny(err)
+			}
+			return true, nil
+		}
+	} else if _, ok := ann[l.annotationKey]; ok {
+		// Lock is not locked
+		return false, nil
+	}
+
+	ann[l.annotationKey] = ""
+	return true, nil
+}
+
+// readLockData reads the lock data from the given annotations.
+// It returns false if the annotations contain no lock data, or
+// if the lock data must be considered free because of an invalid signature.
+func (l *kubeLock) readLockData(ann map[string]string) (LockData, bool, error) {
+	lockData, found, err := l.readLockDataFrom(ann, l.annotationKey)
+	if err != nil {
+		return LockData{}, false, maskAny(err)
+	}
+	return lockData, found, nil
+}
+
+// readLockDataFrom reads the lock data from the annotation with given key.
+func (l *kubeLock) readLockDataFrom(ann map[string]string, annotationKey string) (LockData, bool, error) {
+	lockDataRaw := ann[annotationKey]
+	if lockDataRaw == "" {
+		return LockData{}, false, nil
+	}
+	var lockData LockData
+	if err := json.Unmarshal([]byte(lockDataRaw), &lockData); err != nil {
+		return LockData{}, false, maskAny(err)
+	}
+	if l.signingKey != nil {
+		if err := l.verify(annotationKey, lockData); err != nil {
+			if l.signaturePolicy == SignaturePolicyFree {
+				return LockData{}, false, nil
+			}
+			return LockData{}, false, maskAny(err)
+		}
+	}
+	return lockData, true, nil
+}
+
+// writeLockData signs the given lock data (if needed) and writes it into the given annotations.
+func (l *kubeLock) writeLockData(ann map[string]string, lockData *LockData) error {
+	if l.signingKey != nil {
+		signature, err := l.sign(l.annotationKey, *lockData)
+		if err != nil {
+			return maskAny(err)
+		}
+		lockData.Signature = signature
+	}
+	lockDataRaw, err := json.Marshal(lockData)
+	if err != nil {
+		return maskAny(err)
+	}
+	ann[l.annotationKey] = string(lockDataRaw)
+	return nil
+}
+
 // CurrentOwner fetches the current owner ID of the lock.
 // If the lock is not owner, "" is returned.
 func (l *kubeLock) CurrentOwner() (string, error) {
@@ -173,14 +504,123 @@ func (l *kubeLock) CurrentOwner() (string, error) {
 	}
 
 	// Get lock data
-	if lockDataRaw, ok := ann[l.annotationKey]; ok && lockDataRaw != "" {
-		var lockData LockData
-		if err := json.Unmarshal([]byte(lockDataRaw), &lockData); err != nil {
-			return "", maskAny(err)
-		}
+	if lockData, found, err := l.readLockData(ann); err != nil {
+		return "", maskAny(err)
+	} else if found {
 		return lockData.Owner, nil
 	}
 
 	// No owner found
 	return "", nil
 }
+
+// Data fetches the user data stored with the lock.
+// The user data is kept when the lock is released or changes owner.
+func (l *kubeLock) Data() (map[string]string, error) {
+	// Get current state
+	ann, _, _, err := l.getMeta()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Get lock data
+	lockData, _, err := l.readLockData(ann)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return lockData.Data, nil
+}
+
+// SetData replaces the user data stored with the lock.
+// The lock must be held by us.
+func (l *kubeLock) SetData(data map[string]string) error {
+	// Get current state
+	ann, rv, extra, err := l.getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Update lock data
+	lockData, found, err := l.readLockData(ann)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !found || lockData.Owner != l.ownerID {
+		return maskAny(errgo.WithCausef(nil, NotLockedByMeError, "locked by %s", lockData.Owner))
+	}
+	lockData.Data = data
+	if err := l.writeLockData(ann, &lockData); err != nil {
+		return maskAny(err)
+	}
+
+	// Try to store it now
+	if err := l.updateMeta(ann, rv, extra); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// IsHeld returns true if the last successful acquire made us the holder of the lock
+// and that lock will not expire within the safety margin.
+// IsHeld does not contact the API server.
+func (l *kubeLock) IsHeld() bool {
+	if l.session != nil && !l.session.IsAlive() {
+		return false
+	}
+	return l.clock.Now().Add(l.safetyMargin).Before(l.currentHeldUntil())
+}
+
+// currentHeldUntil returns the expiration time of the lock we hold.
+// Locks bound to a session are held until the session expires, which moves with every
+// renewal of the session, but never beyond the maximum hold duration.
+// A zero time is returned when we do not hold the lock.
+func (l *kubeLock) currentHeldUntil() time.Time {
+	heldUntil := l.getHeldUntil()
+	if l.session == nil || heldUntil.IsZero() {
+		return heldUntil
+	}
+	aliveUntil := l.session.heldUntil()
+	if l.maxHold > 0 && heldUntil.Before(aliveUntil) {
+		// Limited by the maximum hold duration
+		return heldUntil
+	}
+	return aliveUntil
+}
+
+// getHeldUntil returns the expiration time of the lock we hold.
+// A zero time is returned when we do not hold the lock.
+func (l *kubeLock) getHeldUntil() time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.heldUntil
+}
+
+// setHeldUntil records the expiration time of the lock we hold.
+// Pass a zero time when we do not hold the lock.
+func (l *kubeLock) setHeldUntil(t time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.heldUntil = t
+}
+
+// getHoldTTL returns the ttl passed to the last successful acquire.
+// It defaults to the ttl the lock was created with.
+func (l *kubeLock) getHoldTTL() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.holdTTL == 0 {
+		return l.ttl
+	}
+	return l.holdTTL
+}
+
+// setHoldTTL records the ttl passed to a successful acquire.
+func (l *kubeLock) setHoldTTL(ttl time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.holdTTL = ttl
+}
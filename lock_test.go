@@ -0,0 +1,174 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// newTestObject creates an object in a new in-memory backend and returns its get & update functions.
+func newTestObject(t *testing.T) (lock.MetaGetter, lock.MetaUpdater) {
+	b := locktest.NewMemoryBackend()
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return b.Meta("test")
+}
+
+// TestAcquireAfterExpiredHold checks that an owner can acquire its own expired lock
+// again once the maximum hold duration of its previous hold has passed.
+func TestAcquireAfterExpiredHold(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithMaxHoldDuration(time.Hour))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire after expiry failed: %v", err)
+	}
+}
+
+// TestManagerKeepsAcquireTTL checks that a Manager renews a lock with the ttl of its last acquire.
+func TestManagerKeepsAcquireTTL(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.AcquireWithTTL(10 * time.Minute); err != nil {
+		t.Fatalf("AcquireWithTTL failed: %v", err)
+	}
+	m := lock.NewManager()
+	if err := m.Add("test", l); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := m.Renew(); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	if remaining := l.State().HeldUntil.Sub(clock.Now()); remaining != 10*time.Minute {
+		t.Errorf("Expected lock to be held for 10m, got %s", remaining)
+	}
+}
+
+// TestMaxHoldDuration checks that an owner that keeps renewing its lock has to step down
+// once the lock has been extended up to the maximum hold duration.
+func TestMaxHoldDuration(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	start := clock.Now()
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithMaxHoldDuration(2*time.Minute))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	other, err := lock.NewKubeLock("", "other", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// Renew every ttl/2, well beyond the maximum hold duration
+	exceeded := false
+	for i := 0; i < 20; i++ {
+		clock.Advance(30 * time.Second)
+		err := l.Acquire()
+		if exceeded && err == nil {
+			t.Fatalf("Acquire succeeded at %s after the maximum hold duration was exceeded", clock.Now().Sub(start))
+		}
+		if err != nil {
+			if !lock.IsMaxHoldExceeded(err) {
+				t.Fatalf("Expected MaxHoldExceeded, got %v", err)
+			}
+			exceeded = true
+		}
+		if l.IsHeld() && !clock.Now().Before(start.Add(2*time.Minute)) {
+			t.Fatalf("Lock still held at %s", clock.Now().Sub(start))
+		}
+	}
+	if !exceeded {
+		t.Fatalf("Expected MaxHoldExceeded")
+	}
+
+	// Others can take over, we cannot
+	if err := other.Acquire(); err != nil {
+		t.Fatalf("Acquire by other failed: %v", err)
+	}
+	if err := other.Release(); err != nil {
+		t.Fatalf("Release by other failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire after other held the lock failed: %v", err)
+	}
+}
+
+// TestMaxHoldDurationAfterRelease checks that an owner can start a new hold once it
+// released the lock that reached the maximum hold duration.
+func TestMaxHoldDurationAfterRelease(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithMaxHoldDuration(2*time.Minute))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		clock.Advance(30 * time.Second)
+		if err := l.Acquire(); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+	}
+	clock.Advance(30 * time.Second)
+	if err := l.Acquire(); !lock.IsMaxHoldExceeded(err) {
+		t.Fatalf("Expected MaxHoldExceeded, got %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+}
+
+// TestManagerStepsDownAtMaxHold checks that a Manager releases a lock that can no longer
+// be renewed because of the maximum hold duration.
+func TestManagerStepsDownAtMaxHold(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithMaxHoldDuration(2*time.Minute))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	m := lock.NewManager()
+	if err := m.Add("test", l); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	var renewErr error
+	for i := 0; i < 10 && renewErr == nil; i++ {
+		clock.Advance(30 * time.Second)
+		renewErr = m.Renew()
+	}
+	if !lock.IsMaxHoldExceeded(renewErr) {
+		t.Fatalf("Expected MaxHoldExceeded, got %v", renewErr)
+	}
+	if l.IsHeld() {
+		t.Errorf("Expected lock to be no longer held")
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected lock to be released, got owner '%s' (%v)", owner, err)
+	}
+}
@@ -0,0 +1,77 @@
+package locktest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a lock.TimerClock for tests that only moves when told to.
+// Its timers fire when the clock is advanced beyond their deadline.
+type Clock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []clockTimer
+}
+
+type clockTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock creates a Clock that is set to the given time.
+// If the time is zero, the clock starts at a fixed date.
+func NewClock(now time.Time) *Clock {
+	if now.IsZero() {
+		now = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the time of the clock once it has been
+// advanced by the given duration.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := clockTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+	}
+	return t.c
+}
+
+// Timers returns the number of timers that have not fired yet.
+func (c *Clock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by the given duration,
+// firing all timers with a deadline up to the new time.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
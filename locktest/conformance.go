@@ -0,0 +1,320 @@
+// Package locktest provides utilities for testing implementations of the
+// get & update functions used by kube-lock.
+package locktest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// T is the subset of testing.T used by the conformance suite.
+type T interface {
+	Errorf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// Backend is implemented by every storage backend that is run through the conformance suite.
+type Backend interface {
+	// Create creates an object with given name holding the given annotations.
+	// The annotations can be nil.
+	Create(name string, annotations map[string]string) error
+	// Delete removes the object with given name.
+	Delete(name string) error
+	// Meta returns the get & update functions for the object with given name.
+	// The object does not have to exist.
+	Meta(name string) (lock.MetaGetter, lock.MetaUpdater)
+}
+
+const (
+	conformanceTTL   = time.Minute
+	otherAnnotation  = "example.com/kube-lock-conformance"
+	concurrentOwners = 8
+)
+
+type conformanceCase struct {
+	name string
+	run  func(b Backend, name string) error
+}
+
+var conformanceCases = []conformanceCase{
+	{"stale-version", testStaleVersion},
+	{"nil-annotations", testNilAnnotations},
+	{"preserve-annotations", testPreserveAnnotations},
+	{"missing-object", testMissingObject},
+	{"concurrent-writers", testConcurrentWriters},
+	{"concurrent-locks", testConcurrentLocks},
+}
+
+// Run runs the conformance suite against the given backend.
+// Every failing case is reported using t.Errorf.
+func Run(t T, b Backend) {
+	for _, c := range conformanceCases {
+		name := "kube-lock-conformance-" + c.name
+		if err := c.run(b, name); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else {
+			t.Logf("%s: ok", c.name)
+		}
+	}
+}
+
+// create creates an object with given name and returns a function that removes it again.
+func create(b Backend, name string, annotations map[string]string) (func(), error) {
+	if err := b.Create(name, annotations); err != nil {
+		return nil, fmt.Errorf("cannot create object: %v", err)
+	}
+	return func() { b.Delete(name) }, nil
+}
+
+// testStaleVersion checks that an update with an outdated resource version is rejected.
+func testStaleVersion(b Backend, name string) error {
+	cleanup, err := create(b, name, map[string]string{otherAnnotation: "1"})
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	get, update := b.Meta(name)
+	ann, rv, extra, err := get()
+	if err != nil {
+		return fmt.Errorf("get failed: %v", err)
+	}
+	if err := update(map[string]string{otherAnnotation: "2"}, rv, extra); err != nil {
+		return fmt.Errorf("update with current version failed: %v", err)
+	}
+	_, newRV, _, err := get()
+	if err != nil {
+		return fmt.Errorf("get failed: %v", err)
+	}
+	if newRV == rv {
+		return fmt.Errorf("resource version did not change after update")
+	}
+	ann[otherAnnotation] = "3"
+	if err := update(ann, rv, extra); err == nil {
+		return fmt.Errorf("update with stale version %s succeeded", rv)
+	}
+	ann, _, _, err = get()
+	if err != nil {
+		return fmt.Errorf("get failed: %v", err)
+	}
+	if v := ann[otherAnnotation]; v != "2" {
+		return fmt.Errorf("expected annotation value '2', got '%s'", v)
+	}
+	return nil
+}
+
+// testNilAnnotations checks that a lock can be acquired on an object without any annotations.
+func testNilAnnotations(b Backend, name string) error {
+	cleanup, err := create(b, name, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	get, update := b.Meta(name)
+	ann, _, _, err := get()
+	if err != nil {
+		return fmt.Errorf("get failed: %v", err)
+	}
+	if len(ann) != 0 {
+		return fmt.Errorf("expected no annotations, got %v", ann)
+	}
+	l, err := lock.NewKubeLock("", "owner", conformanceTTL, get, update)
+	if err != nil {
+		return fmt.Errorf("cannot create lock: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		return fmt.Errorf("acquire failed: %v", err)
+	}
+	if owner, err := l.CurrentOwner(); err != nil {
+		return fmt.Errorf("cannot get owner: %v", err)
+	} else if owner != "owner" {
+		return fmt.Errorf("expected owner 'owner', got '%s'", owner)
+	}
+	if err := l.Release(); err != nil {
+		return fmt.Errorf("release failed: %v", err)
+	}
+	return nil
+}
+
+// testPreserveAnnotations checks that acquiring & releasing a lock leaves other annotations intact.
+func testPreserveAnnotations(b Backend, name string) error {
+	cleanup, err := create(b, name, map[string]string{otherAnnotation: "keep"})
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	get, update := b.Meta(name)
+	l, err := lock.NewKubeLock("", "owner", conformanceTTL, get, update)
+	if err != nil {
+		return fmt.Errorf("cannot create lock: %v", err)
+	}
+	check := func(step string) error {
+		ann, _, _, err := get()
+		if err != nil {
+			return fmt.Errorf("get after %s failed: %v", step, err)
+		}
+		if v, ok := ann[otherAnnotation]; !ok || v != "keep" {
+			return fmt.Errorf("annotation %s lost or changed after %s: %v", otherAnnotation, step, ann)
+		}
+		return nil
+	}
+	if err := l.Acquire(); err != nil {
+		return fmt.Errorf("acquire failed: %v", err)
+	}
+	if err := check("acquire"); err != nil {
+		return err
+	}
+	if err := l.Release(); err != nil {
+		return fmt.Errorf("release failed: %v", err)
+	}
+	if err := check("release"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// testMissingObject checks that all lock operations fail on an object that does not exist.
+func testMissingObject(b Backend, name string) error {
+	get, update := b.Meta(name)
+	if _, _, _, err := get(); err == nil {
+		return fmt.Errorf("get of missing object succeeded")
+	}
+	l, err := lock.NewKubeLock("", "owner", conformanceTTL, get, update)
+	if err != nil {
+		return fmt.Errorf("cannot create lock: %v", err)
+	}
+	if err := l.Acquire(); err == nil {
+		return fmt.Errorf("acquire on missing object succeeded")
+	}
+	if err := l.Release(); err == nil {
+		return fmt.Errorf("release on missing object succeeded")
+	}
+	if _, err := l.CurrentOwner(); err == nil {
+		return fmt.Errorf("current owner of missing object succeeded")
+	}
+	return nil
+}
+
+// testConcurrentWriters checks that of several updates based on the same resource version,
+// exactly one succeeds.
+func testConcurrentWriters(b Backend, name string) error {
+	cleanup, err := create(b, name, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	get, update := b.Meta(name)
+	type state struct {
+		ann   map[string]string
+		rv    string
+		extra interface{}
+	}
+	states := make([]state, concurrentOwners)
+	for i := range states {
+		ann, rv, extra, err := get()
+		if err != nil {
+			return fmt.Errorf("get failed: %v", err)
+		}
+		if ann == nil {
+			ann = make(map[string]string)
+		}
+		ann[otherAnnotation] = fmt.Sprintf("writer-%d", i)
+		states[i] = state{ann, rv, extra}
+	}
+
+	var wg sync.WaitGroup
+	errors := make([]error, len(states))
+	start := make(chan struct{})
+	for i, s := range states {
+		wg.Add(1)
+		go func(i int, s state) {
+			defer wg.Done()
+			<-start
+			errors[i] = update(s.ann, s.rv, s.extra)
+		}(i, s)
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errors {
+		if err == nil {
+			if winner >= 0 {
+				return fmt.Errorf("both writer-%d and writer-%d succeeded", winner, i)
+			}
+			winner = i
+		}
+	}
+	if winner < 0 {
+		return fmt.Errorf("no writer succeeded")
+	}
+	ann, _, _, err := get()
+	if err != nil {
+		return fmt.Errorf("get failed: %v", err)
+	}
+	if expected := fmt.Sprintf("writer-%d", winner); ann[otherAnnotation] != expected {
+		return fmt.Errorf("expected annotation value '%s', got '%s'", expected, ann[otherAnnotation])
+	}
+	return nil
+}
+
+// testConcurrentLocks checks that of several owners trying to acquire the same lock at the
+// same time, exactly one succeeds.
+func testConcurrentLocks(b Backend, name string) error {
+	cleanup, err := create(b, name, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	get, update := b.Meta(name)
+	locks := make([]lock.KubeLock, concurrentOwners)
+	for i := range locks {
+		l, err := lock.NewKubeLock("", fmt.Sprintf("owner-%d", i), conformanceTTL, get, update)
+		if err != nil {
+			return fmt.Errorf("cannot create lock: %v", err)
+		}
+		locks[i] = l
+	}
+
+	var wg sync.WaitGroup
+	errors := make([]error, len(locks))
+	start := make(chan struct{})
+	for i, l := range locks {
+		wg.Add(1)
+		go func(i int, l lock.KubeLock) {
+			defer wg.Done()
+			<-start
+			errors[i] = l.Acquire()
+		}(i, l)
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errors {
+		if err == nil {
+			if winner >= 0 {
+				return fmt.Errorf("both owner-%d and owner-%d acquired the lock", winner, i)
+			}
+			winner = i
+		}
+	}
+	if winner < 0 {
+		return fmt.Errorf("no owner acquired the lock")
+	}
+	owner, err := locks[0].CurrentOwner()
+	if err != nil {
+		return fmt.Errorf("cannot get owner: %v", err)
+	}
+	if expected := fmt.Sprintf("owner-%d", winner); owner != expected {
+		return fmt.Errorf("expected owner '%s', got '%s'", expected, owner)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package locktest
+
+import (
+	"github.com/juju/errgo"
+)
+
+var (
+	maskAny              = errgo.MaskFunc(errgo.Any)
+	ConflictError        = errgo.New("conflict")
+	NotFoundError        = errgo.New("not found")
+	InjectedError        = errgo.New("injected fault")
+	MutualExclusionError = errgo.New("mutual exclusion violated")
+)
+
+// IsConflict returns true if the given error is caused by a ConflictError error.
+func IsConflict(err error) bool {
+	return errgo.Cause(err) == ConflictError
+}
+
+// IsNotFound returns true if the given error is caused by a NotFoundError error.
+func IsNotFound(err error) bool {
+	return errgo.Cause(err) == NotFoundError
+}
+
+// IsInjected returns true if the given error is caused by a InjectedError error.
+func IsInjected(err error) bool {
+	return errgo.Cause(err) == InjectedError
+}
+
+// IsMutualExclusion returns true if the given error is caused by a MutualExclusionError error.
+func IsMutualExclusion(err error) bool {
+	return errgo.Cause(err) == MutualExclusionError
+}
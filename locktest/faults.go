@@ -0,0 +1,176 @@
+package locktest
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// Faults configures the faults injected by a FaultInjector.
+// All rates are probabilities in the range [0, 1].
+type Faults struct {
+	// Seed for the random generator. If 0, a time based seed is used.
+	// Use FaultInjector.Seed to find the seed of a failing run.
+	Seed int64
+	// MinLatency & MaxLatency bound the random latency added to every call.
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// GetErrorRate is the probability that a get call fails.
+	GetErrorRate float64
+	// UpdateErrorRate is the probability that an update call fails without being applied.
+	UpdateErrorRate float64
+	// ConflictRate is the probability that an update call fails with a ConflictError
+	// without being applied.
+	ConflictRate float64
+	// LostReplyRate is the probability that an update call is applied, but its reply is lost.
+	LostReplyRate float64
+	// Sleep is used to wait for the injected latency. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+}
+
+// FaultStats holds the number of calls & injected faults of a FaultInjector.
+type FaultStats struct {
+	Gets        int
+	Updates     int
+	GetErrors   int
+	Errors      int
+	Conflicts   int
+	LostReplies int
+}
+
+// FaultInjector decorates get & update functions with configurable faults.
+type FaultInjector struct {
+	mutex       sync.Mutex
+	faults      Faults
+	random      *rand.Rand
+	get         lock.MetaGetter
+	update      lock.MetaUpdater
+	partitioned bool
+	stats       FaultStats
+}
+
+// NewFaultInjector creates a FaultInjector that decorates the given get & update functions.
+func NewFaultInjector(get lock.MetaGetter, update lock.MetaUpdater, faults Faults) *FaultInjector {
+	if faults.Seed == 0 {
+		faults.Seed = time.Now().UnixNano()
+	}
+	if faults.Sleep == nil {
+		faults.Sleep = time.Sleep
+	}
+	return &FaultInjector{
+		faults: faults,
+		random: rand.New(rand.NewSource(faults.Seed)),
+		get:    get,
+		update: update,
+	}
+}
+
+// Seed returns the seed used by the random generator.
+func (f *FaultInjector) Seed() int64 {
+	return f.faults.Seed
+}
+
+// SetPartitioned enables or disables a network partition.
+// While partitioned, all get calls fail and all update calls are applied, but their reply is lost.
+func (f *FaultInjector) SetPartitioned(partitioned bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.partitioned = partitioned
+}
+
+// Stats returns the number of calls & injected faults so far.
+func (f *FaultInjector) Stats() FaultStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stats
+}
+
+// Meta returns the decorated get & update functions.
+func (f *FaultInjector) Meta() (lock.MetaGetter, lock.MetaUpdater) {
+	return f.metaGet, f.metaUpdate
+}
+
+// fault describes the faults selected for a single call.
+type fault struct {
+	latency     time.Duration
+	fail        bool
+	conflict    bool
+	lostReply   bool
+	partitioned bool
+}
+
+// next selects the faults for the next call.
+func (f *FaultInjector) next(isUpdate bool) fault {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var result fault
+	result.latency = f.faults.MinLatency
+	if spread := f.faults.MaxLatency - f.faults.MinLatency; spread > 0 {
+		result.latency += time.Duration(f.random.Int63n(int64(spread)))
+	}
+	result.partitioned = f.partitioned
+	if isUpdate {
+		f.stats.Updates++
+		switch {
+		case f.partitioned:
+			f.stats.LostReplies++
+		case f.chance(f.faults.UpdateErrorRate):
+			result.fail = true
+			f.stats.Errors++
+		case f.chance(f.faults.ConflictRate):
+			result.conflict = true
+			f.stats.Conflicts++
+		case f.chance(f.faults.LostReplyRate):
+			result.lostReply = true
+			f.stats.LostReplies++
+		}
+	} else {
+		f.stats.Gets++
+		if f.partitioned || f.chance(f.faults.GetErrorRate) {
+			result.fail = true
+			f.stats.GetErrors++
+		}
+	}
+	return result
+}
+
+// chance returns true with the given probability.
+// Must be called with the mutex held.
+func (f *FaultInjector) chance(rate float64) bool {
+	return rate > 0 && f.random.Float64() < rate
+}
+
+func (f *FaultInjector) metaGet() (map[string]string, string, interface{}, error) {
+	injected := f.next(false)
+	f.faults.Sleep(injected.latency)
+	if injected.fail {
+		return nil, "", nil, maskAny(errgo.WithCausef(nil, InjectedError, "get failed"))
+	}
+	ann, rv, extra, err := f.get()
+	if err != nil {
+		return nil, "", nil, maskAny(err)
+	}
+	return ann, rv, extra, nil
+}
+
+func (f *FaultInjector) metaUpdate(annotations map[string]string, resourceVersion string, extra interface{}) error {
+	injected := f.next(true)
+	f.faults.Sleep(injected.latency)
+	if injected.fail {
+		return maskAny(errgo.WithCausef(nil, InjectedError, "update failed"))
+	}
+	if injected.conflict {
+		return maskAny(errgo.WithCausef(nil, ConflictError, "injected conflict"))
+	}
+	if err := f.update(annotations, resourceVersion, extra); err != nil {
+		return maskAny(err)
+	}
+	if injected.lostReply || injected.partitioned {
+		return maskAny(errgo.WithCausef(nil, InjectedError, "update reply lost"))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package locktest
+
+import (
+	"sync"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// LeaseBackend is an in-memory Backend that stores locks in Leases using lock.NewLeaseMeta.
+// Like MemoryBackend, it mimics the optimistic concurrency behavior of the Kubernetes API server.
+type LeaseBackend struct {
+	annotationKey string
+	objects       *MemoryBackend
+
+	mutex  sync.Mutex
+	leases map[string]lock.LeaseRecord
+}
+
+// NewLeaseBackend creates a new, empty LeaseBackend that mirrors the lock with given
+// annotation key into the spec of its Leases.
+func NewLeaseBackend(annotationKey string) *LeaseBackend {
+	return &LeaseBackend{
+		annotationKey: annotationKey,
+		objects:       NewMemoryBackend(),
+		leases:        make(map[string]lock.LeaseRecord),
+	}
+}
+
+// Create creates a Lease with given name holding the given annotations and an empty spec.
+func (b *LeaseBackend) Create(name string, annotations map[string]string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.objects.Create(name, annotations); err != nil {
+		return maskAny(err)
+	}
+	b.leases[name] = lock.LeaseRecord{}
+	return nil
+}
+
+// Delete removes the Lease with given name.
+func (b *LeaseBackend) Delete(name string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.objects.Delete(name); err != nil {
+		return maskAny(err)
+	}
+	delete(b.leases, name)
+	return nil
+}
+
+// Lease returns the spec of the Lease with given name.
+func (b *LeaseBackend) Lease(name string) (lock.LeaseRecord, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	record, found := b.leases[name]
+	if !found {
+		return lock.LeaseRecord{}, maskAny(errgo.WithCausef(nil, NotFoundError, "lease %s", name))
+	}
+	return record, nil
+}
+
+// SetLease replaces the spec of the Lease with given name, like a client that does
+// not use kube-lock would do.
+func (b *LeaseBackend) SetLease(name string, record lock.LeaseRecord) error {
+	get, update := b.leaseMeta(name)
+	_, ann, rv, extra, err := get()
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := update(record, ann, rv, extra); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Meta returns the get & update functions for the Lease with given name.
+// The Lease does not have to exist.
+func (b *LeaseBackend) Meta(name string) (lock.MetaGetter, lock.MetaUpdater) {
+	get, update := b.leaseMeta(name)
+	return lock.NewLeaseMeta(b.annotationKey, get, update)
+}
+
+// leaseMeta returns the functions that get & update the spec and annotations of the
+// Lease with given name in a single step.
+func (b *LeaseBackend) leaseMeta(name string) (lock.LeaseGetter, lock.LeaseUpdater) {
+	metaGet, metaUpdate := b.objects.Meta(name)
+	get := func() (lock.LeaseRecord, map[string]string, string, interface{}, error) {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		ann, rv, extra, err := metaGet()
+		if err != nil {
+			return lock.LeaseRecord{}, nil, "", nil, maskAny(err)
+		}
+		return b.leases[name], ann, rv, extra, nil
+	}
+	update := func(record lock.LeaseRecord, annotations map[string]string, resourceVersion string, extra interface{}) error {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		if err := metaUpdate(annotations, resourceVersion, extra); err != nil {
+			return maskAny(err)
+		}
+		b.leases[name] = record
+		return nil
+	}
+	return get, update
+}
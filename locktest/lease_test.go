@@ -0,0 +1,151 @@
+package locktest
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// TestLeaseBackend runs the conformance suite against the in-memory lease backend.
+func TestLeaseBackend(t *testing.T) {
+	Run(t, NewLeaseBackend(""))
+}
+
+// TestLeaseExternalHolder checks that a Lease held by a client that does not use
+// kube-lock is respected until it expires, and that the spec follows the lock afterwards.
+func TestLeaseExternalHolder(t *testing.T) {
+	b := NewLeaseBackend("")
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	now := time.Now()
+	if err := b.SetLease("test", lock.LeaseRecord{
+		HolderIdentity:       "other",
+		LeaseDurationSeconds: 60,
+		AcquireTime:          now,
+		RenewTime:            now,
+		LeaseTransitions:     3,
+	}); err != nil {
+		t.Fatalf("SetLease failed: %v", err)
+	}
+
+	get, update := b.Meta("test")
+	l, err := lock.NewKubeLock("", "me", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); !lock.IsAlreadyLocked(err) {
+		t.Fatalf("Expected AlreadyLocked, got %v", err)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "other" {
+		t.Fatalf("Expected owner 'other', got '%s' (%v)", owner, err)
+	}
+
+	// External holder expires
+	if err := b.SetLease("test", lock.LeaseRecord{
+		HolderIdentity:       "other",
+		LeaseDurationSeconds: 60,
+		AcquireTime:          now.Add(-2 * time.Minute),
+		RenewTime:            now.Add(-2 * time.Minute),
+		LeaseTransitions:     3,
+	}); err != nil {
+		t.Fatalf("SetLease failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	record, err := b.Lease("test")
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if record.HolderIdentity != "me" {
+		t.Errorf("Expected holder 'me', got '%s'", record.HolderIdentity)
+	}
+	if record.LeaseTransitions != 4 {
+		t.Errorf("Expected 4 transitions, got %d", record.LeaseTransitions)
+	}
+	if record.LeaseDurationSeconds != 60 {
+		t.Errorf("Expected a lease duration of 60s, got %d", record.LeaseDurationSeconds)
+	}
+
+	// Renewal by the same holder is not a transition
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if record, err := b.Lease("test"); err != nil || record.LeaseTransitions != 4 {
+		t.Errorf("Expected 4 transitions after renewal, got %d (%v)", record.LeaseTransitions, err)
+	}
+}
+
+// TestLeaseRelease checks that releasing the lock clears the holder of the Lease.
+func TestLeaseRelease(t *testing.T) {
+	b := NewLeaseBackend("")
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	get, update := b.Meta("test")
+	l, err := lock.NewKubeLock("", "me", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	record, err := b.Lease("test")
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if record.HolderIdentity != "" {
+		t.Errorf("Expected no holder, got '%s'", record.HolderIdentity)
+	}
+	if record.LeaseTransitions != 1 {
+		t.Errorf("Expected 1 transition, got %d", record.LeaseTransitions)
+	}
+
+	// Someone else can take over
+	other, err := lock.NewKubeLock("", "other", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := other.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if record, err := b.Lease("test"); err != nil || record.HolderIdentity != "other" || record.LeaseTransitions != 2 {
+		t.Errorf("Expected holder 'other' after 2 transitions, got '%s' after %d (%v)", record.HolderIdentity, record.LeaseTransitions, err)
+	}
+}
+
+// TestLeaseUsesLockClock checks that the times in the Lease are derived from the
+// clock of the lock, not from the system clock.
+func TestLeaseUsesLockClock(t *testing.T) {
+	b := NewLeaseBackend("")
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	now := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	get, update := b.Meta("test")
+	l, err := lock.NewKubeLock("", "me", time.Minute, get, update, lock.WithClock(NewClock(now)))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	record, err := b.Lease("test")
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if !record.AcquireTime.Equal(now) {
+		t.Errorf("Expected acquire time %s, got %s", now, record.AcquireTime)
+	}
+	if !record.RenewTime.Equal(now) {
+		t.Errorf("Expected renew time %s, got %s", now, record.RenewTime)
+	}
+	if record.LeaseDurationSeconds != 60 {
+		t.Errorf("Expected a lease duration of 60s, got %d", record.LeaseDurationSeconds)
+	}
+}
@@ -0,0 +1,109 @@
+package locktest
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// MemoryBackend is an in-memory Backend that mimics the optimistic concurrency
+// behavior of the Kubernetes API server.
+// Every successful update bumps the resource version of an object and updates that
+// do not carry the current resource version are rejected with a ConflictError.
+type MemoryBackend struct {
+	mutex   sync.Mutex
+	objects map[string]*memoryObject
+	version int64
+}
+
+type memoryObject struct {
+	annotations     map[string]string
+	resourceVersion string
+}
+
+// NewMemoryBackend creates a new, empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		objects: make(map[string]*memoryObject),
+	}
+}
+
+// Create creates an object with given name holding the given annotations.
+func (b *MemoryBackend) Create(name string, annotations map[string]string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, found := b.objects[name]; found {
+		return maskAny(errgo.WithCausef(nil, ConflictError, "object %s already exists", name))
+	}
+	b.objects[name] = &memoryObject{
+		annotations:     copyAnnotations(annotations),
+		resourceVersion: b.nextResourceVersion(),
+	}
+	return nil
+}
+
+// Delete removes the object with given name.
+func (b *MemoryBackend) Delete(name string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, found := b.objects[name]; !found {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "object %s", name))
+	}
+	delete(b.objects, name)
+	return nil
+}
+
+// Meta returns the get & update functions for the object with given name.
+// The object does not have to exist.
+func (b *MemoryBackend) Meta(name string) (lock.MetaGetter, lock.MetaUpdater) {
+	get := func() (map[string]string, string, interface{}, error) {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		obj, found := b.objects[name]
+		if !found {
+			return nil, "", nil, maskAny(errgo.WithCausef(nil, NotFoundError, "object %s", name))
+		}
+		return copyAnnotations(obj.annotations), obj.resourceVersion, nil, nil
+	}
+	update := func(annotations map[string]string, resourceVersion string, extra interface{}) error {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		obj, found := b.objects[name]
+		if !found {
+			return maskAny(errgo.WithCausef(nil, NotFoundError, "object %s", name))
+		}
+		if obj.resourceVersion != resourceVersion {
+			return maskAny(errgo.WithCausef(nil, ConflictError, "object %s has version %s, got %s", name, obj.resourceVersion, resourceVersion))
+		}
+		obj.annotations = copyAnnotations(annotations)
+		obj.resourceVersion = b.nextResourceVersion()
+		return nil
+	}
+	return get, update
+}
+
+// nextResourceVersion returns a new unique resource version.
+// Must be called with the mutex held.
+func (b *MemoryBackend) nextResourceVersion() string {
+	b.version++
+	return strconv.FormatInt(b.version, 10)
+}
+
+// copyAnnotations returns a copy of the given annotations.
+// A nil map is returned as nil, just like the API server omits empty annotations.
+func copyAnnotations(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		return nil
+	}
+	result := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		result[k] = v
+	}
+	return result
+}
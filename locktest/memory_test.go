@@ -0,0 +1,10 @@
+package locktest
+
+import (
+	"testing"
+)
+
+// TestMemoryBackend runs the conformance suite against the in-memory backend.
+func TestMemoryBackend(t *testing.T) {
+	Run(t, NewMemoryBackend())
+}
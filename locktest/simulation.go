@@ -0,0 +1,326 @@
+package locktest
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// SimulationConfig configures a mutual exclusion simulation.
+type SimulationConfig struct {
+	// Seed for the random generator. If 0, a time based seed is used.
+	Seed int64
+	// Contenders is the number of lock instances competing for the lock. Defaults to 5.
+	Contenders int
+	// Duration is the simulated duration of the run. Defaults to 1 hour.
+	Duration time.Duration
+	// TTL of the lock. Defaults to 10 seconds.
+	TTL time.Duration
+	// MaxClockSkew is the maximum difference between the clocks of any two contenders.
+	MaxClockSkew time.Duration
+	// MaxDelay is the maximum random delay between two actions of a contender.
+	// Defaults to TTL/2.
+	MaxDelay time.Duration
+	// ReleaseRate is the probability that a holder releases the lock instead of renewing it.
+	ReleaseRate float64
+	// Faults are injected in the backend of every contender.
+	// The seed & sleep function are set by the simulation.
+	Faults Faults
+}
+
+// HoldPeriod is a period in which an owner believed it held the lock.
+// Start & End are expressed in simulated time without clock skew.
+type HoldPeriod struct {
+	Owner string
+	Start time.Time
+	End   time.Time
+}
+
+// SimulationResult holds the outcome of a simulation.
+type SimulationResult struct {
+	// Seed used by the simulation.
+	Seed int64
+	// Periods in which an owner believed it held the lock, ordered by start time.
+	Periods []HoldPeriod
+}
+
+// Simulate runs many lock instances against a shared in-memory backend, using a simulated
+// clock, random delays & injected faults.
+// It records every period each owner believed it held the lock and returns an error
+// caused by MutualExclusionError if any two of them overlap.
+// An owner believes it holds the lock from the moment its acquire succeeded until TTL minus
+// MaxClockSkew after it started that acquire call.
+// Runs with the same configuration & seed are reproducible.
+func Simulate(config SimulationConfig) (SimulationResult, error) {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if config.Contenders == 0 {
+		config.Contenders = 5
+	}
+	if config.Duration == 0 {
+		config.Duration = time.Hour
+	}
+	if config.TTL == 0 {
+		config.TTL = time.Second * 10
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = config.TTL / 2
+	}
+	result := SimulationResult{Seed: config.Seed}
+
+	backend := NewMemoryBackend()
+	const objectName = "simulation"
+	if err := backend.Create(objectName, nil); err != nil {
+		return result, maskAny(err)
+	}
+	get, update := backend.Meta(objectName)
+
+	sim := newSimulator(config.Seed, config.Duration)
+	contenders := make([]*contender, config.Contenders)
+	for i := range contenders {
+		faults := config.Faults
+		faults.Seed = sim.random.Int63() + 1
+		faults.Sleep = sim.Sleep
+		injector := NewFaultInjector(get, update, faults)
+		skew := time.Duration(0)
+		if config.MaxClockSkew > 0 {
+			skew = time.Duration(sim.random.Int63n(int64(config.MaxClockSkew)))
+		}
+		ownerID := fmt.Sprintf("contender-%d", i)
+		injectorGet, injectorUpdate := injector.Meta()
+		l, err := lock.NewKubeLock("", ownerID, config.TTL, injectorGet, injectorUpdate, lock.WithClock(skewedClock{sim, skew}))
+		if err != nil {
+			return result, maskAny(err)
+		}
+		contenders[i] = &contender{
+			ownerID: ownerID,
+			lock:    l,
+			config:  config,
+			sim:     sim,
+		}
+	}
+
+	sim.run(len(contenders), func(i int) {
+		contenders[i].run()
+	})
+
+	for _, c := range contenders {
+		result.Periods = append(result.Periods, c.periods...)
+	}
+	sort.Slice(result.Periods, func(i, j int) bool {
+		return result.Periods[i].Start.Before(result.Periods[j].Start)
+	})
+	for i, p := range result.Periods {
+		for _, q := range result.Periods[i+1:] {
+			if !q.Start.Before(p.End) {
+				break
+			}
+			if p.Owner != q.Owner {
+				return result, maskAny(errgo.WithCausef(nil, MutualExclusionError,
+					"%s held the lock from %s until %s, %s from %s until %s (seed %d)",
+					p.Owner, p.Start.Format(time.RFC3339Nano), p.End.Format(time.RFC3339Nano),
+					q.Owner, q.Start.Format(time.RFC3339Nano), q.End.Format(time.RFC3339Nano), config.Seed))
+			}
+		}
+	}
+	return result, nil
+}
+
+// contender is a single lock instance in a simulation.
+type contender struct {
+	ownerID string
+	lock    lock.KubeLock
+	config  SimulationConfig
+	sim     *simulator
+	periods []HoldPeriod
+	holding bool
+}
+
+// run acquires, renews & releases the lock at random moments until the simulation ends.
+func (c *contender) run() {
+	for {
+		c.sim.Sleep(c.sim.randomDuration(c.config.MaxDelay))
+		if c.holding && c.sim.chance(c.config.ReleaseRate) {
+			c.endPeriod(c.sim.Now())
+			c.holding = false
+			c.lock.Release()
+			continue
+		}
+		start := c.sim.Now()
+		if err := c.lock.Acquire(); err != nil {
+			if c.holding {
+				c.endPeriod(c.sim.Now())
+				c.holding = false
+			}
+			continue
+		}
+		now := c.sim.Now()
+		end := start.Add(c.config.TTL - c.config.MaxClockSkew)
+		if c.holding && now.Before(c.periods[len(c.periods)-1].End) {
+			// Renewed in time, extend the current period
+			if p := &c.periods[len(c.periods)-1]; end.After(p.End) {
+				p.End = end
+			}
+		} else if now.Before(end) {
+			c.periods = append(c.periods, HoldPeriod{Owner: c.ownerID, Start: now, End: end})
+		}
+		c.holding = now.Before(end)
+	}
+}
+
+// endPeriod stops the current period at the given time, unless it already ended earlier.
+func (c *contender) endPeriod(t time.Time) {
+	if len(c.periods) == 0 {
+		return
+	}
+	if p := &c.periods[len(c.periods)-1]; t.Before(p.End) {
+		p.End = t
+	}
+}
+
+// skewedClock is a Clock that runs ahead of the simulated time by a fixed amount.
+type skewedClock struct {
+	sim  *simulator
+	skew time.Duration
+}
+
+// Now returns the simulated time plus the skew.
+func (c skewedClock) Now() time.Time {
+	return c.sim.Now().Add(c.skew)
+}
+
+// simulator runs goroutines one at a time in simulated time.
+// A goroutine runs until it calls Sleep, after which the goroutine with
+// the earliest wake up time continues.
+type simulator struct {
+	mutex   sync.Mutex
+	random  *rand.Rand
+	now     time.Time
+	end     time.Time
+	waiters waiterQueue
+	seq     int64
+	yield   chan struct{}
+	stopped bool
+}
+
+type waiter struct {
+	at   time.Time
+	seq  int64
+	wake chan struct{}
+}
+
+func newSimulator(seed int64, duration time.Duration) *simulator {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &simulator{
+		random: rand.New(rand.NewSource(seed)),
+		now:    start,
+		end:    start.Add(duration),
+		yield:  make(chan struct{}),
+	}
+}
+
+// Now returns the simulated time.
+func (s *simulator) Now() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.now
+}
+
+// Sleep blocks the calling goroutine for the given duration of simulated time.
+// When the simulation has ended, the calling goroutine is stopped.
+func (s *simulator) Sleep(d time.Duration) {
+	s.mutex.Lock()
+	w := &waiter{at: s.now.Add(d), seq: s.seq, wake: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiters, w)
+	s.mutex.Unlock()
+
+	s.yield <- struct{}{}
+	<-w.wake
+
+	s.mutex.Lock()
+	stopped := s.stopped
+	s.mutex.Unlock()
+	if stopped {
+		runtime.Goexit()
+	}
+}
+
+// randomDuration returns a random duration in the range [0, max).
+func (s *simulator) randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return time.Duration(s.random.Int63n(int64(max)))
+}
+
+// chance returns true with the given probability.
+func (s *simulator) chance(rate float64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return rate > 0 && s.random.Float64() < rate
+}
+
+// run starts the given number of goroutines and schedules them until the simulation ends.
+// Every goroutine must call Sleep regularly.
+func (s *simulator) run(count int, f func(i int)) {
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer func() { s.yield <- struct{}{} }()
+			f(i)
+		}(i)
+		// Wait until the goroutine sleeps
+		<-s.yield
+	}
+	for {
+		s.mutex.Lock()
+		w := heap.Pop(&s.waiters).(*waiter)
+		if w.at.After(s.end) {
+			// Stop all goroutines
+			s.stopped = true
+			heap.Push(&s.waiters, w)
+			waiters := s.waiters
+			s.waiters = nil
+			s.mutex.Unlock()
+			for _, w := range waiters {
+				close(w.wake)
+				<-s.yield
+			}
+			return
+		}
+		s.now = w.at
+		s.mutex.Unlock()
+		close(w.wake)
+		<-s.yield
+	}
+}
+
+// waiterQueue is a heap of waiters, ordered by wake up time.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q waiterQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *waiterQueue) Push(x interface{}) { *q = append(*q, x.(*waiter)) }
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
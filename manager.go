@@ -0,0 +1,292 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+// Manager renews & releases many locks.
+// Locks are grouped by the resource that holds their data, and all locks
+// of a single resource are renewed & released using a single get & update.
+// All locks added to a Manager for the same resource must use the same
+// get & update functions.
+type Manager struct {
+	mutex  sync.Mutex
+	groups map[string][]*kubeLock
+}
+
+// NewManager creates a new, empty Manager.
+func NewManager() *Manager {
+	return &Manager{
+		groups: make(map[string][]*kubeLock),
+	}
+}
+
+// Add adds the given lock to the manager.
+// The resource identifies the resource that holds the lock data, e.g. "namespace/service/name".
+// The manager only renews the lock while we hold it, it does not acquire it.
+func (m *Manager) Add(resource string, l ExtendedLock) error {
+	kl := l.impl()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, existing := range m.groups[resource] {
+		if existing == kl {
+			return nil
+		}
+		if existing.annotationKey == kl.annotationKey {
+			return maskAny(fmt.Errorf("resource %s already has a lock with annotation key %s", resource, kl.annotationKey))
+		}
+	}
+	m.groups[resource] = append(m.groups[resource], kl)
+	return nil
+}
+
+// Remove removes the given lock from the manager.
+// The lock is not released.
+func (m *Manager) Remove(l ExtendedLock) {
+	kl := l.impl()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for resource, locks := range m.groups {
+		for i, existing := range locks {
+			if existing == kl {
+				locks = append(locks[:i], locks[i+1:]...)
+				if len(locks) == 0 {
+					delete(m.groups, resource)
+				} else {
+					m.groups[resource] = locks
+				}
+				return
+			}
+		}
+	}
+}
+
+// Renew renews all locks that we hold, using a single get & update per resource.
+// Every lock is renewed with the ttl of its last successful acquire (see AcquireWithTTL).
+// Locks that can no longer be renewed safely, because the observed round trip time leaves
+// no room for several renewal attempts within their ttl, or because they reached their
+// maximum hold duration (see WithMaxHoldDuration), are released in the same update.
+// If the renewal of one or more locks failed, the first error is returned.
+// Use IsHeld to find out which locks are still held.
+func (m *Manager) Renew() error {
+	var firstErr error
+	for _, locks := range m.snapshot() {
+		if err := renewLocks(locks); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	return nil
+}
+
+// ReleaseAll releases all locks that we own, using a single get & update per resource.
+// If the release of one or more resources failed, the first error is returned.
+func (m *Manager) ReleaseAll() error {
+	var firstErr error
+	for _, locks := range m.snapshot() {
+		if err := releaseLocks(locks); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	return nil
+}
+
+// Run renews all locks that we hold until the given context is canceled.
+// Renewal starts early enough to leave room for several attempts, based on the observed
+// round trip time. When no lock is held, Run checks every half of the shortest ttl.
+// When the context is canceled, all locks that we own are released.
+// Run returns the error of the final release, renewal errors are not returned.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := false
+	for {
+		select {
+		case <-time.After(m.renewDelay(failed)):
+			failed = m.Renew() != nil
+		case <-ctx.Done():
+			if err := m.ReleaseAll(); err != nil {
+				return maskAny(err)
+			}
+			return nil
+		}
+	}
+}
+
+// snapshot returns a copy of all lock groups.
+func (m *Manager) snapshot() [][]*kubeLock {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make([][]*kubeLock, 0, len(m.groups))
+	for _, locks := range m.groups {
+		result = append(result, append([]*kubeLock(nil), locks...))
+	}
+	return result
+}
+
+// renewDelay returns the time until the first lock we hold should be renewed.
+// If no lock is held, half of the shortest ttl of all locks is returned.
+func (m *Manager) renewDelay(failed bool) time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var ttl, delay time.Duration
+	held := false
+	for _, locks := range m.groups {
+		for _, l := range locks {
+			if ttl == 0 || l.ttl < ttl {
+				ttl = l.ttl
+			}
+			if l.session == nil && !l.getHeldUntil().IsZero() {
+				now := l.clock.Now()
+				if d := l.nextRenewal(now, failed).Sub(now); !held || d < delay {
+					delay = d
+				}
+				held = true
+			}
+		}
+	}
+	if held {
+		return delay
+	}
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return ttl / 2
+}
+
+// renewLocks renews all given locks that we hold.
+// All locks must be stored in the same resource.
+func renewLocks(locks []*kubeLock) error {
+	var held []*kubeLock
+	for _, l := range locks {
+		// Locks bound to a session are renewed by their session
+		if l.session == nil && !l.getHeldUntil().IsZero() {
+			held = append(held, l)
+		}
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	// Get current state
+	start := held[0].clock.Now()
+	ann, rv, extra, err := held[0].getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Update lock data of all locks
+	if ann == nil {
+		ann = make(map[string]string)
+	}
+	var firstErr error
+	var renewed []*kubeLock
+	var expiresAt []time.Time
+	released := false
+	for _, l := range held {
+		err := l.checkRenewal()
+		if err == nil && !l.renewalTimeLeft(l.clock.Now()) {
+			err = maskAny(errgo.WithCausef(nil, RenewalUnsafeError, "no time left for another renewal"))
+		}
+		if err != nil {
+			// Step down while we still hold the lock
+			l.setHeldUntil(time.Time{})
+			if changed, _ := l.releaseIn(ann); changed {
+				released = true
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		heldUntil, err := l.acquireIn(ann, l.getHoldTTL())
+		if err != nil {
+			if IsAlreadyLocked(err) || IsLockFrozen(err) {
+				l.setHeldUntil(time.Time{})
+			} else if IsMaxHoldExceeded(err) {
+				// Step down, so others can take over
+				l.setHeldUntil(time.Time{})
+				if changed, _ := l.releaseIn(ann); changed {
+					released = true
+				}
+			}
+			l.recordAttempt(start, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		renewed = append(renewed, l)
+		expiresAt = append(expiresAt, heldUntil)
+	}
+
+	// Try to renew them now
+	if len(renewed) > 0 || released {
+		err := held[0].updateMeta(ann, rv, extra)
+		roundTrip := held[0].clock.Now().Sub(start)
+		for _, l := range held {
+			l.recordRoundTrip(roundTrip)
+		}
+		for _, l := range renewed {
+			l.recordAttempt(start, err)
+		}
+		if err != nil {
+			return maskAny(err)
+		}
+		for i, l := range renewed {
+			l.setHeldUntil(expiresAt[i])
+		}
+	}
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	return nil
+}
+
+// releaseLocks releases all given locks that we own.
+// All locks must be stored in the same resource.
+func releaseLocks(locks []*kubeLock) error {
+	for _, l := range locks {
+		l.setHeldUntil(time.Time{})
+	}
+
+	// Get current state
+	ann, rv, extra, err := locks[0].getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Update lock data of all locks we own
+	if ann == nil {
+		return nil
+	}
+	changed := false
+	for _, l := range locks {
+		if ann[l.annotationKey] == "" {
+			continue
+		}
+		if lockChanged, err := l.releaseIn(ann); err == nil && lockChanged {
+			changed = true
+		}
+	}
+
+	// Try to release them now
+	if changed {
+		if err := locks[0].updateMeta(ann, rv, extra); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"time"
+)
+
+// Option is used to customize a KubeLock created by NewKubeLock.
+type Option func(*kubeLock)
+
+// Clock provides the current time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// TimerClock is a Clock that also provides timers.
+// When the clock passed to WithClock implements TimerClock, its timers are used to wait
+// for renewals & polls, so tests can control them.
+type TimerClock interface {
+	Clock
+	// After waits for the given duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+// Now returns the current time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// after waits for the given duration on the clock of the lock.
+func (l *kubeLock) after(d time.Duration) <-chan time.Time {
+	if tc, ok := l.clock.(TimerClock); ok {
+		return tc.After(d)
+	}
+	return time.After(d)
+}
+
+// WithClock configures the clock used to calculate and check lock expiration.
+// If the clock implements TimerClock, it is used for timers as well.
+// By default the system clock is used.
+func WithClock(clock Clock) Option {
+	return func(l *kubeLock) {
+		l.clock = clock
+	}
+}
+
+// WithMaxHoldDuration limits the total duration that an owner can hold the lock.
+// The hold starts when the owner acquires a lock that is not held by it.
+// Once a renewal has extended the lock up to the maximum duration, further renewals fail
+// with a MaxHoldExceededError and the owner must step down. It cannot acquire the lock
+// again until it has released it, or another owner has held it in the meantime.
+// By default the hold duration is unlimited.
+func WithMaxHoldDuration(maxHold time.Duration) Option {
+	return func(l *kubeLock) {
+		l.maxHold = maxHold
+	}
+}
+
+// WithSafetyMargin configures the duration before the expiration of the lock
+// at which IsHeld starts to report that the lock is no longer held.
+// Use it to stop working as the holder of the lock before others can take over,
+// allowing for clock skew between instances.
+// The default margin is 0.
+func WithSafetyMargin(margin time.Duration) Option {
+	return func(l *kubeLock) {
+		l.safetyMargin = margin
+	}
+}
+
+// WithSigningKey enables HMAC signing of the lock data using the given shared secret.
+// All instances competing for the lock must use the same key.
+// The policy determines how lock data with a bad or missing signature is treated.
+// Signatures cover the annotation key, but not the resource, so signed lock data that is copied to
+// the same annotation of another resource is accepted there. Use different secrets for locks in
+// resources that must not accept each other's lock data.
+func WithSigningKey(key []byte, policy SignaturePolicy) Option {
+	return func(l *kubeLock) {
+		l.signingKey = key
+		l.signaturePolicy = policy
+	}
+}
+
+// WithSession binds the lock to the given session.
+// The lock is held for as long as the session is alive, it does not have to be renewed
+// and the ttl of the lock is not used.
+// The session must be stored in the same resource as the lock, so every instance can
+// check the liveness of the session that holds the lock, with or without a session of its own.
+func WithSession(session *Session) Option {
+	return func(l *kubeLock) {
+		l.session = session
+	}
+}
+
+// WithWatcher configures a watcher that is used by WaitUntilFree & WaitForOwnerChange
+// to detect changes of the lock data without waiting for the next poll.
+func WithWatcher(watcher MetaWatcher) Option {
+	return func(l *kubeLock) {
+		l.watchMeta = watcher
+	}
+}
+
+// WithPollInterval configures the interval at which WaitUntilFree & WaitForOwnerChange
+// fetch the lock data. The default interval is 2 seconds.
+func WithPollInterval(interval time.Duration) Option {
+	return func(l *kubeLock) {
+		l.pollInterval = interval
+	}
+}
+
+// WithHistory records the last ownership transitions of the lock in the annotation with given key,
+// keeping at most size entries. The history is updated in the same write as the lock data.
+// If the annotation key is empty, the annotation key of the lock with a ".history" suffix is used.
+// All instances competing for the lock should use the same history options.
+func WithHistory(annotationKey string, size int) Option {
+	return func(l *kubeLock) {
+		if annotationKey == "" {
+			annotationKey = l.annotationKey + HistorySuffix
+		}
+		if size <= 0 {
+			size = defaultHistorySize
+		}
+		l.historyKey = annotationKey
+		l.historySize = size
+	}
+}
@@ -0,0 +1,21 @@
+package probe
+
+import (
+	"github.com/juju/errgo"
+)
+
+var (
+	maskAny             = errgo.MaskFunc(errgo.Any)
+	NotLeaderError      = errgo.New("not leader")
+	RenewalStalledError = errgo.New("renewal stalled")
+)
+
+// IsNotLeader returns true if the given error is caused by a NotLeaderError error.
+func IsNotLeader(err error) bool {
+	return errgo.Cause(err) == NotLeaderError
+}
+
+// IsRenewalStalled returns true if the given error is caused by a RenewalStalledError error.
+func IsRenewalStalled(err error) bool {
+	return errgo.Cause(err) == RenewalStalledError
+}
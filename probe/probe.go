@@ -0,0 +1,117 @@
+// Package probe provides readiness & liveness probe handlers driven by the state of a lock.
+//
+// Use a leader readiness probe to make only the holder of a lock Ready, so a Service
+// routes traffic to it. Use a renewal liveness probe to have a pod restarted when it
+// still considers itself the holder of a lock, but has not renewed it for too long.
+package probe
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+// Probe checks a condition and serves the outcome over HTTP.
+// It responds with 200 when the check succeeds and with 503 otherwise.
+type Probe interface {
+	http.Handler
+
+	// Check returns nil when the probe succeeds, or an error describing why it fails.
+	Check() error
+}
+
+// LeaderConfig configures a leader readiness probe.
+type LeaderConfig struct {
+	// MinRemaining is the minimum time the lock must still be held for the probe to succeed.
+	// Use it to stop receiving traffic shortly before the lock could expire. Defaults to 0.
+	MinRemaining time.Duration
+	// Clock used to check the remaining time. Defaults to the system clock.
+	// Use the same clock as the lock.
+	Clock lock.Clock
+}
+
+// RenewalConfig configures a renewal liveness probe.
+type RenewalConfig struct {
+	// MaxStall is the maximum time since the last successful acquire or renewal of a lock
+	// that we still consider held. Defaults to 1 minute.
+	MaxStall time.Duration
+	// Clock used to check the time since the last renewal. Defaults to the system clock.
+	// Use the same clock as the lock.
+	Clock lock.Clock
+}
+
+const (
+	defaultMaxStall = time.Minute
+)
+
+// NewLeaderReadiness creates a readiness probe that succeeds only while we hold the given lock.
+func NewLeaderReadiness(l lock.ExtendedLock, config LeaderConfig) Probe {
+	return &probe{check: func() error {
+		state := l.State()
+		if !state.Held {
+			return maskAny(errgo.WithCausef(nil, NotLeaderError, "lock %s is not held by %s", state.AnnotationKey, state.OwnerID))
+		}
+		if remaining := state.HeldUntil.Sub(now(config.Clock)); remaining < config.MinRemaining {
+			return maskAny(errgo.WithCausef(nil, NotLeaderError, "lock %s expires in %s", state.AnnotationKey, remaining))
+		}
+		return nil
+	}}
+}
+
+// NewRenewalLiveness creates a liveness probe that fails when we still consider ourselves
+// the holder of the given lock, but have not acquired or renewed it for longer than the
+// configured maximum stall. Followers always pass this probe.
+// A lock bound to a session is considered renewed whenever its session is kept alive.
+func NewRenewalLiveness(l lock.ExtendedLock, config RenewalConfig) Probe {
+	if config.MaxStall == 0 {
+		config.MaxStall = defaultMaxStall
+	}
+	return &probe{check: func() error {
+		state := l.State()
+		if state.HeldUntil.IsZero() {
+			// Not holding the lock, nothing to renew
+			return nil
+		}
+		if stall := now(config.Clock).Sub(state.LastSuccess); stall > config.MaxStall {
+			if state.LastError != "" {
+				return maskAny(errgo.WithCausef(nil, RenewalStalledError, "lock %s not renewed for %s: %s", state.AnnotationKey, stall, state.LastError))
+			}
+			return maskAny(errgo.WithCausef(nil, RenewalStalledError, "lock %s not renewed for %s", state.AnnotationKey, stall))
+		}
+		return nil
+	}}
+}
+
+// probe implements Probe using a check function.
+type probe struct {
+	check func() error
+}
+
+// Check returns nil when the probe succeeds, or an error describing why it fails.
+func (p *probe) Check() error {
+	if err := p.check(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// ServeHTTP responds with 200 when the check succeeds and with 503 otherwise.
+func (p *probe) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := p.check(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// now returns the current time of the given clock, or the system clock if nil.
+func now(clock lock.Clock) time.Time {
+	if clock != nil {
+		return clock.Now()
+	}
+	return time.Now()
+}
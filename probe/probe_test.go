@@ -0,0 +1,55 @@
+package probe
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// TestRenewalLivenessWithSession checks that a lock bound to a session that is kept alive
+// passes the liveness probe, long after it was acquired.
+func TestRenewalLivenessWithSession(t *testing.T) {
+	b := locktest.NewMemoryBackend()
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	get, update := b.Meta("test")
+	clock := locktest.NewClock(time.Now())
+	session, err := lock.NewSession("", "owner", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if err := session.KeepAlive(); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithSession(session))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	liveness := NewRenewalLiveness(l, RenewalConfig{MaxStall: time.Minute, Clock: clock})
+	readiness := NewLeaderReadiness(l, LeaderConfig{MinRemaining: time.Second * 10, Clock: clock})
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Second * 30)
+		if err := session.KeepAlive(); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+	}
+	if err := liveness.Check(); err != nil {
+		t.Errorf("Expected liveness to pass, got %v", err)
+	}
+	if err := readiness.Check(); err != nil {
+		t.Errorf("Expected readiness to pass, got %v", err)
+	}
+
+	// Session is no longer kept alive
+	clock.Advance(time.Minute * 2)
+	if err := readiness.Check(); !IsNotLeader(err) {
+		t.Errorf("Expected NotLeader, got %v", err)
+	}
+}
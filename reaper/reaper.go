@@ -0,0 +1,290 @@
+// Package reaper provides a controller that clears expired lock data.
+//
+// Lock data is left behind in annotations when the holder of a lock crashes
+// without releasing it. The reaper scans resources for such lock data and
+// clears every record that expired a grace period ago.
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+var (
+	maskAny = errgo.MaskFunc(errgo.Any)
+)
+
+// Resource is a resource that may hold lock data.
+type Resource struct {
+	// Name of the resource, used for reporting only.
+	Name string
+	// Get & Update functions of the resource.
+	Get    lock.MetaGetter
+	Update lock.MetaUpdater
+}
+
+// Lister returns the resources to scan.
+type Lister func() ([]Resource, error)
+
+// ReapFunc is called for every lock record that has been cleared.
+type ReapFunc func(resource Resource, annotationKey string, lockData lock.LockData)
+
+// ErrorFunc is called when a scan fails.
+type ErrorFunc func(err error)
+
+// Config holds the configuration of a Reaper.
+type Config struct {
+	// AnnotationPrefix selects the annotations that hold lock data.
+	// Every annotation with a key that starts with this prefix is scanned.
+	// Defaults to "pulcy.com/kube-lock", which includes keyed locks & sessions.
+	AnnotationPrefix string
+	// SessionAnnotationPrefix selects the annotations that hold liveness records of sessions.
+	// Expired liveness records are deleted instead of cleared.
+	// Defaults to "pulcy.com/kube-lock-session".
+	SessionAnnotationPrefix string
+	// GracePeriod is the time that lock data must have been expired before it is cleared.
+	// Defaults to 10 minutes.
+	GracePeriod time.Duration
+	// Interval between two scans. Defaults to 1 minute.
+	Interval time.Duration
+	// History makes the reaper record every cleared hold in the history annotation of the lock
+	// (its annotation key with lock.HistorySuffix), even when that annotation does not exist yet.
+	// Existing history annotations are always updated, so they stay complete.
+	// Histories stored under other annotation keys (see lock.WithHistory) are not updated.
+	History bool
+	// HistorySize is the number of entries kept in a history.
+	// It should match the size given to lock.WithHistory. Defaults to 10.
+	HistorySize int
+	// Clock used to check expiration. Defaults to the system clock.
+	Clock lock.Clock
+	// OnReap is called for every lock record that has been cleared.
+	OnReap ReapFunc
+	// OnError is called by Run when a scan fails.
+	OnError ErrorFunc
+}
+
+// Reaper clears expired lock data from resources.
+type Reaper struct {
+	config Config
+	list   Lister
+}
+
+const (
+	defaultAnnotationPrefix        = "pulcy.com/kube-lock"
+	defaultSessionAnnotationPrefix = "pulcy.com/kube-lock-session"
+	defaultGracePeriod             = time.Minute * 10
+	defaultInterval                = time.Minute
+)
+
+// New creates a new Reaper that scans the resources returned by the given lister.
+func New(config Config, list Lister) *Reaper {
+	if config.AnnotationPrefix == "" {
+		config.AnnotationPrefix = defaultAnnotationPrefix
+	}
+	if config.SessionAnnotationPrefix == "" {
+		config.SessionAnnotationPrefix = defaultSessionAnnotationPrefix
+	}
+	if config.GracePeriod == 0 {
+		config.GracePeriod = defaultGracePeriod
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+	}
+	return &Reaper{
+		config: config,
+		list:   list,
+	}
+}
+
+// Run scans all resources every interval, until the given context is canceled.
+// Errors of individual scans are not returned, but passed to OnError.
+func (r *Reaper) Run(ctx context.Context) error {
+	for {
+		if _, err := r.Scan(); err != nil && r.config.OnError != nil {
+			r.config.OnError(err)
+		}
+		select {
+		case <-time.After(r.config.Interval):
+			// Continue
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Scan scans all resources once and clears all expired lock data.
+// It returns the number of cleared lock records.
+// If one or more resources cannot be scanned or updated, the first error is returned.
+// Records without an owner, which only hold user data, and frozen records are left alone.
+// Expired liveness records of sessions, and empty ones left behind by older versions, are deleted.
+// Clearing a record keeps its user data in a record without owner, like Admin.Break does.
+// Signed records with user data are left alone, since the reaper cannot sign the new record.
+// They become free when they expire anyway.
+// Cleared holds are recorded in the history of the lock (see Config.History) in the same write.
+func (r *Reaper) Scan() (int, error) {
+	resources, err := r.list()
+	if err != nil {
+		return 0, maskAny(err)
+	}
+
+	// Fetch the current state of all resources
+	type state struct {
+		resource    Resource
+		annotations map[string]string
+		rv          string
+		extra       interface{}
+	}
+	var firstErr error
+	var states []state
+	for _, res := range resources {
+		ann, rv, extra, err := res.Get()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = maskAny(err)
+			}
+			continue
+		}
+		states = append(states, state{res, ann, rv, extra})
+	}
+
+	// Clear all expired lock data
+	reaped := 0
+	now := r.now()
+	for _, s := range states {
+		// Holds are checked against the annotations as they were fetched,
+		// since liveness records of sessions may be deleted in this loop.
+		original := make(map[string]string, len(s.annotations))
+		for key, raw := range s.annotations {
+			original[key] = raw
+		}
+		var cleared []string
+		var clearedData []lock.LockData
+		changed := false
+		for key, raw := range original {
+			if !r.isLockAnnotation(key) {
+				continue
+			}
+			if raw == "" {
+				if r.isSessionAnnotation(key) {
+					// Left behind by a closed session
+					delete(s.annotations, key)
+					changed = true
+				}
+				continue
+			}
+			var lockData lock.LockData
+			if err := json.Unmarshal([]byte(raw), &lockData); err != nil {
+				// Not lock data
+				continue
+			}
+			if lockData.Owner == "" || lockData.Frozen {
+				// Not locked, but holding user data, or frozen by an administrator
+				continue
+			}
+			if !r.isStale(lockData, original, now) {
+				continue
+			}
+			if r.isSessionAnnotation(key) {
+				delete(s.annotations, key)
+			} else if len(lockData.Data) != 0 && lockData.Signature != "" {
+				// Cannot keep the user data without signing it
+				continue
+			} else {
+				free := ""
+				if len(lockData.Data) != 0 {
+					raw, err := json.Marshal(lock.LockData{Data: lockData.Data})
+					if err != nil {
+						continue
+					}
+					free = string(raw)
+				}
+				if err := r.appendHistory(s.annotations, original, key, lockData, now); err != nil {
+					continue
+				}
+				s.annotations[key] = free
+			}
+			changed = true
+			cleared = append(cleared, key)
+			clearedData = append(clearedData, lockData)
+		}
+		if !changed {
+			continue
+		}
+		if err := s.resource.Update(s.annotations, s.rv, s.extra); err != nil {
+			if firstErr == nil {
+				firstErr = maskAny(err)
+			}
+			continue
+		}
+		reaped += len(cleared)
+		if r.config.OnReap != nil {
+			for i, key := range cleared {
+				r.config.OnReap(s.resource, key, clearedData[i])
+			}
+		}
+	}
+	if firstErr != nil {
+		return reaped, maskAny(firstErr)
+	}
+	return reaped, nil
+}
+
+// appendHistory records the end of the hold described by the given lock data, which is stored in the
+// annotation with given key, in the history of that lock (see Config.History).
+// The hold end is read from the given original annotations.
+func (r *Reaper) appendHistory(ann, original map[string]string, key string, lockData lock.LockData, now time.Time) error {
+	historyKey := key + lock.HistorySuffix
+	if _, found := ann[historyKey]; !found && !r.config.History {
+		return nil
+	}
+	endedAt, err := lock.ReadHoldEnd(original, lockData)
+	if err != nil || endedAt.IsZero() {
+		// Session has been closed at an unknown time
+		endedAt = now
+	}
+	entry := lock.HistoryEntry{
+		Owner:      lockData.Owner,
+		AcquiredAt: lockData.AcquiredAt,
+		EndedAt:    endedAt,
+		Reason:     lock.HistoryReasonReaped,
+	}
+	if err := lock.AppendHistory(ann, historyKey, r.config.HistorySize, entry); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// isLockAnnotation returns true if the annotation with given key can hold lock data.
+func (r *Reaper) isLockAnnotation(key string) bool {
+	return strings.HasPrefix(key, r.config.AnnotationPrefix)
+}
+
+// isSessionAnnotation returns true if the annotation with given key holds the liveness record of a session.
+func (r *Reaper) isSessionAnnotation(key string) bool {
+	return strings.HasPrefix(key, r.config.SessionAnnotationPrefix+".")
+}
+
+// isStale returns true if the given lock data expired more than the grace period ago.
+// Lock data bound to a session is stale when the liveness record of that session, which is
+// stored in the same resource (given annotations), has been removed or is stale (see lock.ReadHoldEnd).
+func (r *Reaper) isStale(lockData lock.LockData, ann map[string]string, now time.Time) bool {
+	end, err := lock.ReadHoldEnd(ann, lockData)
+	if err != nil {
+		// Corrupt liveness record, the session cannot be alive
+		return true
+	}
+	return now.After(end.Add(r.config.GracePeriod))
+}
+
+// now returns the current time.
+func (r *Reaper) now() time.Time {
+	if r.config.Clock != nil {
+		return r.config.Clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,136 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// newTestReaper creates a Reaper that scans a single in-memory object.
+func newTestReaper(t *testing.T, clock lock.Clock) (*Reaper, lock.MetaGetter, lock.MetaUpdater) {
+	b := locktest.NewMemoryBackend()
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	get, update := b.Meta("test")
+	list := func() ([]Resource, error) {
+		return []Resource{{Name: "test", Get: get, Update: update}}, nil
+	}
+	return New(Config{Clock: clock, GracePeriod: time.Minute}, list), get, update
+}
+
+// TestScanDeletesSessions checks that expired liveness records of sessions and
+// empty ones are deleted, and that locks bound to them are cleared.
+func TestScanDeletesSessions(t *testing.T) {
+	clock := locktest.NewClock(time.Now())
+	r, get, update := newTestReaper(t, clock)
+	session, err := lock.NewSession("", "owner", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if err := session.KeepAlive(); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithSession(session))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// Add an empty session record, as left behind by older versions
+	ann, rv, extra, err := get()
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	ann["pulcy.com/kube-lock-session.0011223344556677"] = ""
+	if err := update(ann, rv, extra); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	// Nothing is stale yet
+	if reaped, err := r.Scan(); err != nil || reaped != 0 {
+		t.Fatalf("Expected nothing to be reaped, got %d (%v)", reaped, err)
+	}
+
+	// Session crashed
+	clock.Advance(time.Hour)
+	if reaped, err := r.Scan(); err != nil || reaped != 2 {
+		t.Fatalf("Expected 2 records to be reaped, got %d (%v)", reaped, err)
+	}
+	ann, _, _, err = get()
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(ann) != 1 || ann["pulcy.com/kube-lock"] != "" {
+		t.Errorf("Expected only a cleared lock annotation, got %v", ann)
+	}
+}
+
+// TestScanKeepsData checks that clearing an expired lock keeps its user data.
+func TestScanKeepsData(t *testing.T) {
+	clock := locktest.NewClock(time.Now())
+	r, get, update := newTestReaper(t, clock)
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := l.SetData(map[string]string{"last-completed": "yesterday"}); err != nil {
+		t.Fatalf("SetData failed: %v", err)
+	}
+
+	// Owner crashed
+	clock.Advance(time.Hour)
+	if reaped, err := r.Scan(); err != nil || reaped != 1 {
+		t.Fatalf("Expected 1 record to be reaped, got %d (%v)", reaped, err)
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected no owner, got '%s' (%v)", owner, err)
+	}
+	if data, err := l.Data(); err != nil || data["last-completed"] != "yesterday" {
+		t.Errorf("Expected user data to be kept, got %v (%v)", data, err)
+	}
+}
+
+// TestScanRecordsHistory checks that clearing an expired lock adds the end of its hold
+// to the history of the lock.
+func TestScanRecordsHistory(t *testing.T) {
+	clock := locktest.NewClock(time.Now())
+	r, get, update := newTestReaper(t, clock)
+	l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithClock(clock), lock.WithHistory("", 0))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	expiresAt := clock.Now().Add(time.Minute)
+
+	// Owner crashed
+	clock.Advance(time.Hour)
+	if reaped, err := r.Scan(); err != nil || reaped != 1 {
+		t.Fatalf("Expected 1 record to be reaped, got %d (%v)", reaped, err)
+	}
+	history, err := l.History()
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %v", history)
+	}
+	if e := history[1]; e.Owner != "owner" || e.Reason != lock.HistoryReasonReaped || !e.EndedAt.Equal(expiresAt) {
+		t.Errorf("Expected reaped entry ending at %s, got %v", expiresAt, e)
+	}
+}
@@ -0,0 +1,83 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+const (
+	// renewalAttempts is the number of renewal attempts that must fit in the ttl of a lock,
+	// using the observed round trip time.
+	renewalAttempts = 3
+)
+
+// recordRoundTrip records the duration of a get & update of the lock data.
+// The estimated round trip time follows increases immediately and decreases slowly.
+func (l *kubeLock) recordRoundTrip(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastRoundTrip = d
+	if d > l.roundTrip {
+		l.roundTrip = d
+	} else {
+		l.roundTrip = (3*l.roundTrip + d) / 4
+	}
+}
+
+// getRoundTrip returns the estimated round trip time of a get & update of the lock data.
+func (l *kubeLock) getRoundTrip() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.roundTrip
+}
+
+// checkRenewal returns an error caused by RenewalUnsafeError if the estimated round trip time
+// leaves no room for several renewal attempts within the ttl of the lock.
+func (l *kubeLock) checkRenewal() error {
+	rtt := l.getRoundTrip()
+	if window := l.getHoldTTL() - l.safetyMargin; renewalAttempts*rtt > window {
+		return maskAny(errgo.WithCausef(nil, RenewalUnsafeError, "round trip time of %s leaves no room for %d renewals within %s", rtt, renewalAttempts, window))
+	}
+	return nil
+}
+
+// nextRenewal returns the time at which the lock we hold should be renewed.
+// Renewal starts early enough to leave room for several attempts, based on the
+// estimated round trip time, but never later than a quarter of the ttl before the
+// lock is no longer considered held.
+// After a failed attempt, the remaining attempts are spread over the time that is left.
+func (l *kubeLock) nextRenewal(now time.Time, failed bool) time.Time {
+	heldUntil := l.getHeldUntil()
+	if heldUntil.IsZero() {
+		// Not held, try again soon
+		return now.Add(l.ttl / 4)
+	}
+	rtt := l.getRoundTrip()
+	deadline := heldUntil.Add(-l.safetyMargin)
+	if failed {
+		wait := (deadline.Sub(now) - rtt) / renewalAttempts
+		if wait < 0 {
+			wait = 0
+		}
+		return now.Add(wait)
+	}
+	ttl := l.getHoldTTL()
+	reserve := 2 * renewalAttempts * rtt
+	if min := ttl / 4; reserve < min {
+		reserve = min
+	}
+	if max := (ttl - l.safetyMargin) / 2; reserve > max {
+		reserve = max
+	}
+	return deadline.Add(-reserve)
+}
+
+// renewalTimeLeft returns true if there is still time for a renewal attempt before
+// the lock we hold is no longer considered held.
+func (l *kubeLock) renewalTimeLeft(now time.Time) bool {
+	deadline := l.getHeldUntil().Add(-l.safetyMargin)
+	return now.Add(l.getRoundTrip()).Before(deadline)
+}
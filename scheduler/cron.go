@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar & dowStar are set when the day of month or day of week field starts with '*'.
+	domStar, dowStar bool
+}
+
+// field describes the range of a single field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField = field{"minute", 0, 59}
+	hourField   = field{"hour", 0, 23}
+	domField    = field{"day of month", 1, 31}
+	monthField  = field{"month", 1, 12}
+	dowField    = field{"day of week", 0, 7}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// ParseSchedule parses a standard cron expression with 5 fields:
+// minute, hour, day of month, month & day of week.
+// Every field can be '*', a number, a range 'a-b', a list 'a,b' and a step '*/n' or 'a-b/n'.
+// Day of week 0 and 7 are both Sunday.
+// The descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight & @hourly are also supported.
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, maskAny(fmt.Errorf("cron expression '%s' must have 5 fields, got %d", expr, len(fields)))
+	}
+	s := &Schedule{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	for i, f := range []struct {
+		field field
+		bits  *uint64
+	}{
+		{minuteField, &s.minute},
+		{hourField, &s.hour},
+		{domField, &s.dom},
+		{monthField, &s.month},
+		{dowField, &s.dow},
+	} {
+		bits, err := parseField(fields[i], f.field)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		*f.bits = bits
+	}
+	// Sunday can be 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseField parses a single field of a cron expression into a bit set.
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, maskAny(fmt.Errorf("invalid step in %s field '%s'", f.name, value))
+			}
+			rangePart, step = part[:i], n
+		}
+		first, last := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if first, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, maskAny(fmt.Errorf("invalid %s field '%s'", f.name, value))
+			}
+			last = first
+			if len(bounds) == 2 {
+				if last, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, maskAny(fmt.Errorf("invalid %s field '%s'", f.name, value))
+				}
+			} else if step > 1 {
+				// 'a/n' means 'a-max/n'
+				last = f.max
+			}
+		}
+		if first < f.min || last > f.max || first > last {
+			return 0, maskAny(fmt.Errorf("%s field '%s' out of range %d-%d", f.name, value, f.min, f.max))
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// maxSearchYears limits the search for the next tick of a schedule that never matches,
+// such as '0 0 31 2 *'.
+const maxSearchYears = 5
+
+// Next returns the first tick of the schedule after the given time.
+// Ticks are whole minutes in the location of the given time.
+// If the schedule never matches, the zero time is returned.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay returns true if the day of the given time matches the schedule.
+// When both day of month & day of week are restricted, a day matches if either matches.
+func (s *Schedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,318 @@
+// Package scheduler runs cron jobs in every replica of a deployment, such that
+// only a single replica runs each tick of a job.
+//
+// Every tick of a job uses a lock of a KeyedLock, keyed by the name of the job and the tick.
+// At each tick, all replicas try to acquire that lock. The replica that gets it
+// runs the job and stores the tick as the last completed run in the lock record.
+// Replicas that get the lock later find that the tick has already been completed and skip it.
+//
+// Once a tick has been completed, the records of earlier ticks of the same job are removed,
+// unless they are still held, so only the record of the last completed tick stays behind.
+// That record is used to skip late replicas and to detect missed ticks (see OnMissed).
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+	lock "github.com/pulcy/kube-lock"
+)
+
+var (
+	maskAny = errgo.MaskFunc(errgo.Any)
+)
+
+// Job is the function that is run at every tick of a schedule.
+// The context is canceled when the lock is lost.
+type Job func(ctx context.Context, tick time.Time) error
+
+// SkipFunc is called when a tick of a job is skipped, because another replica runs it
+// or has already completed it.
+type SkipFunc func(name string, tick time.Time)
+
+// MissedFunc is called when one or more ticks of a job have not been run since its last
+// completed tick.
+type MissedFunc func(name string, lastCompleted, tick time.Time)
+
+// ErrorFunc is called when a tick of a job failed.
+type ErrorFunc func(name string, tick time.Time, err error)
+
+// Config holds the configuration of a Scheduler.
+type Config struct {
+	// Location in which cron expressions are interpreted. Defaults to local time.
+	Location *time.Location
+	// Clock used to find the next tick. Defaults to the system clock.
+	Clock lock.Clock
+	// OnSkip is called for every tick that is skipped.
+	OnSkip SkipFunc
+	// OnMissed is called when ticks have been missed before running a tick.
+	OnMissed MissedFunc
+	// OnError is called for every tick that failed.
+	OnError ErrorFunc
+}
+
+// Scheduler runs jobs according to their cron schedules.
+type Scheduler struct {
+	config Config
+	locks  lock.KeyedLock
+
+	mutex sync.Mutex
+	jobs  map[string]*job
+}
+
+type job struct {
+	name     string
+	schedule *Schedule
+	run      Job
+}
+
+const (
+	// lastCompletedKey is the key of the last completed tick in the data of a lock.
+	lastCompletedKey = "last-completed"
+	// setDataAttempts is the maximum number of attempts to store the last completed tick.
+	setDataAttempts = 5
+)
+
+// New creates a new Scheduler that uses the given keyed lock to coordinate with other replicas.
+func New(locks lock.KeyedLock, config Config) *Scheduler {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	return &Scheduler{
+		config: config,
+		locks:  locks,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Add adds a job with given name & cron expression (see ParseSchedule).
+// The name is used in the keys of the locks of the job, so it must be the same in all replicas.
+// Jobs must be added before Run is called.
+func (s *Scheduler) Add(name, expr string, run Job) error {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := s.locks.Lock(tickKey(name, s.now())); err != nil {
+		return maskAny(err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.jobs[name]; found {
+		return maskAny(fmt.Errorf("job %s already exists", name))
+	}
+	s.jobs[name] = &job{
+		name:     name,
+		schedule: schedule,
+		run:      run,
+	}
+	return nil
+}
+
+// Run runs all jobs at their ticks until the given context is canceled.
+// Run returns when all running jobs have finished.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mutex.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.runJob(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runJob runs the given job at every tick until the given context is canceled.
+func (s *Scheduler) runJob(ctx context.Context, j *job) {
+	for {
+		tick := j.schedule.Next(s.now())
+		if tick.IsZero() {
+			// Schedule never matches
+			return
+		}
+		select {
+		case <-time.After(tick.Sub(s.now())):
+			// Continue
+		case <-ctx.Done():
+			return
+		}
+		if err := s.RunTick(ctx, j.name, tick); err != nil {
+			if lock.IsAlreadyLocked(err) {
+				if s.config.OnSkip != nil {
+					s.config.OnSkip(j.name, tick)
+				}
+			} else if s.config.OnError != nil {
+				s.config.OnError(j.name, tick, err)
+			}
+		}
+	}
+}
+
+// RunTick runs the job with given name for the given tick, unless that tick (or a later one)
+// has already been completed.
+// If another replica holds the lock of the tick, an error caused by AlreadyLockedError is returned.
+func (s *Scheduler) RunTick(ctx context.Context, name string, tick time.Time) error {
+	s.mutex.Lock()
+	j, found := s.jobs[name]
+	s.mutex.Unlock()
+	if !found {
+		return maskAny(fmt.Errorf("job %s not found", name))
+	}
+	key := tickKey(name, tick)
+	l, err := s.locks.Lock(key)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := lock.Do(ctx, l, func(ctx context.Context) error {
+		// Was this tick (or a later one) already completed?
+		keys, err := s.tickKeys(name)
+		if err != nil {
+			return maskAny(err)
+		}
+		lastCompleted, err := s.lastCompleted(keys)
+		if err != nil {
+			return maskAny(err)
+		}
+		if !lastCompleted.IsZero() {
+			if !lastCompleted.Before(tick) {
+				if s.config.OnSkip != nil {
+					s.config.OnSkip(name, tick)
+				}
+				return nil
+			}
+			if next := j.schedule.Next(lastCompleted.In(tick.Location())); next.Before(tick) && s.config.OnMissed != nil {
+				s.config.OnMissed(name, lastCompleted, tick)
+			}
+		}
+
+		// Run the job
+		if err := j.run(ctx, tick); err != nil {
+			return maskAny(err)
+		}
+
+		// Record completion
+		data, err := l.Data()
+		if err != nil {
+			return maskAny(err)
+		}
+		newData := make(map[string]string)
+		for k, v := range data {
+			newData[k] = v
+		}
+		newData[lastCompletedKey] = tick.UTC().Format(time.RFC3339Nano)
+		if err := setData(l, newData); err != nil {
+			return maskAny(err)
+		}
+
+		// Remove the records of earlier ticks.
+		// Records that cannot be removed now are removed after a later tick.
+		for _, k := range keys {
+			if k.tick.Before(tick) {
+				s.locks.Remove(k.key)
+			}
+		}
+		return nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// tickKey returns the key of the lock of the given tick of the job with given name.
+func tickKey(name string, tick time.Time) string {
+	return name + "." + strconv.FormatInt(tick.Unix(), 10)
+}
+
+// keyOfTick is the key of the lock of a tick.
+type keyOfTick struct {
+	key  string
+	tick time.Time
+}
+
+// tickKeys fetches the keys of the locks of all ticks of the job with given name
+// that have a record, latest tick first.
+func (s *Scheduler) tickKeys(name string) ([]keyOfTick, error) {
+	keys, err := s.locks.Keys()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []keyOfTick
+	prefix := name + "."
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64)
+		if err != nil {
+			// Lock of another job
+			continue
+		}
+		result = append(result, keyOfTick{key: key, tick: time.Unix(seconds, 0)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].tick.After(result[j].tick)
+	})
+	return result, nil
+}
+
+// lastCompleted returns the last completed tick stored in the records of the given locks,
+// or a zero time if none of the ticks has been completed.
+func (s *Scheduler) lastCompleted(keys []keyOfTick) (time.Time, error) {
+	for _, k := range keys {
+		l, err := s.locks.Lock(k.key)
+		if err != nil {
+			return time.Time{}, maskAny(err)
+		}
+		data, err := l.Data()
+		if err != nil {
+			return time.Time{}, maskAny(err)
+		}
+		if raw, ok := data[lastCompletedKey]; ok {
+			lastCompleted, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return time.Time{}, maskAny(err)
+			}
+			return lastCompleted, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// setData stores the given data with the given lock.
+// The update can conflict with a renewal of the lock by Do, so it is retried
+// for as long as we still hold the lock.
+func setData(l lock.ExtendedLock, data map[string]string) error {
+	for attempt := 1; ; attempt++ {
+		err := l.SetData(data)
+		if err == nil {
+			return nil
+		}
+		if lock.IsNotLockedByMe(err) || !l.IsHeld() || attempt >= setDataAttempts {
+			return maskAny(err)
+		}
+	}
+}
+
+// now returns the current time in the location of the scheduler.
+func (s *Scheduler) now() time.Time {
+	if s.config.Clock != nil {
+		return s.config.Clock.Now().In(s.config.Location)
+	}
+	return time.Now().In(s.config.Location)
+}
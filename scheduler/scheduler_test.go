@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// TestRunTickRetriesSetData checks that a tick that ran is recorded, even when storing
+// its completion conflicts with another update of the lock.
+func TestRunTickRetriesSetData(t *testing.T) {
+	b := locktest.NewMemoryBackend()
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	get, update := b.Meta("test")
+	updates := 0
+	conflictingUpdate := func(annotations map[string]string, resourceVersion string, extra interface{}) error {
+		updates++
+		if updates == 2 {
+			// Conflict with the update that stores the completed tick
+			return errors.New("conflict")
+		}
+		return update(annotations, resourceVersion, extra)
+	}
+	locks, err := lock.NewKeyedLock("", "owner", time.Minute, get, conflictingUpdate)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	s := New(locks, Config{Location: time.UTC})
+	runs := 0
+	if err := s.Add("job", "@hourly", func(ctx context.Context, tick time.Time) error {
+		runs++
+		return nil
+	}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	tick := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.RunTick(context.Background(), "job", tick); err != nil {
+		t.Fatalf("RunTick failed: %v", err)
+	}
+	if err := s.RunTick(context.Background(), "job", tick); err != nil {
+		t.Fatalf("Second RunTick failed: %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("Expected job to run once, got %d", runs)
+	}
+}
+
+// TestRunTickRemovesEarlierTicks checks that every tick uses its own lock, that the records of
+// earlier ticks are removed once a tick completes, and that earlier ticks are skipped afterwards.
+func TestRunTickRemovesEarlierTicks(t *testing.T) {
+	b := locktest.NewMemoryBackend()
+	if err := b.Create("test", nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	get, update := b.Meta("test")
+	locks, err := lock.NewKeyedLock("", "owner", time.Minute, get, update)
+	if err != nil {
+		t.Fatalf("NewKeyedLock failed: %v", err)
+	}
+	var missed []time.Time
+	s := New(locks, Config{
+		Location: time.UTC,
+		OnMissed: func(name string, lastCompleted, tick time.Time) {
+			missed = append(missed, tick)
+		},
+	})
+	var runs []time.Time
+	if err := s.Add("job", "@hourly", func(ctx context.Context, tick time.Time) error {
+		runs = append(runs, tick)
+		return nil
+	}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	tick := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, d := range []time.Duration{0, time.Hour, time.Hour * 3} {
+		if err := s.RunTick(context.Background(), "job", tick.Add(d)); err != nil {
+			t.Fatalf("RunTick failed: %v", err)
+		}
+	}
+	if len(runs) != 3 {
+		t.Errorf("Expected 3 runs, got %v", runs)
+	}
+	if len(missed) != 1 || !missed[0].Equal(tick.Add(time.Hour*3)) {
+		t.Errorf("Expected the last tick to report missed ticks, got %v", missed)
+	}
+	keys, err := locks.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if expected := tickKey("job", tick.Add(time.Hour*3)); len(keys) != 1 || keys[0] != expected {
+		t.Errorf("Expected only key %s, got %v", expected, keys)
+	}
+
+	// A late replica must not run an earlier tick, even though its record has been removed
+	if err := s.RunTick(context.Background(), "job", tick.Add(time.Hour)); err != nil {
+		t.Fatalf("RunTick failed: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Errorf("Expected earlier tick to be skipped, got %v", runs)
+	}
+}
@@ -0,0 +1,186 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+// Session is a single liveness record that is shared by many locks.
+// Locks bound to a session (see WithSession) are held for as long as the session is alive,
+// so only the session has to be renewed, not every lock.
+// When the session expires or is closed, all locks bound to it become free at once.
+//
+// The liveness record is stored as lock data in an annotation with a unique key,
+// formed from an annotation prefix and a random session ID.
+type Session struct {
+	lock *kubeLock
+
+	// updateMutex serializes KeepAlive & Close, which contact the API server.
+	// IsAlive only needs mutex, so it never waits for the API server.
+	updateMutex sync.Mutex
+
+	mutex   sync.Mutex
+	started bool
+	closed  bool
+}
+
+const (
+	defaultSessionAnnotationPrefix = "pulcy.com/kube-lock-session"
+	sessionIDLength                = 8
+)
+
+// NewSession creates a new Session.
+// The session is not alive until KeepAlive has been called.
+func NewSession(annotationPrefix, ownerID string, ttl time.Duration, metaGet MetaGetter, metaUpdate MetaUpdater, options ...Option) (*Session, error) {
+	if annotationPrefix == "" {
+		annotationPrefix = defaultSessionAnnotationPrefix
+	}
+	id := make([]byte, sessionIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return nil, maskAny(err)
+	}
+	l, err := NewKubeLock(annotationPrefix+"."+hex.EncodeToString(id), ownerID, ttl, metaGet, metaUpdate, options...)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &Session{
+		lock: l.impl(),
+	}, nil
+}
+
+// ID returns the identifier of the session.
+// It is the key of the annotation that holds the liveness record.
+func (s *Session) ID() string {
+	return s.lock.annotationKey
+}
+
+// KeepAlive creates or renews the liveness record of the session.
+// Call KeepAlive every ttl/2 (or use Run).
+// Once the session has expired or has been closed, it can never become alive again.
+// KeepAlive then returns a SessionExpiredError.
+func (s *Session) KeepAlive() error {
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	if err := s.checkOpen(); err != nil {
+		return maskAny(err)
+	}
+	if err := s.lock.Acquire(); err != nil {
+		return maskAny(err)
+	}
+	s.mutex.Lock()
+	s.started = true
+	s.mutex.Unlock()
+	return nil
+}
+
+// checkOpen returns a SessionExpiredError when the session has been closed or has expired.
+// A session that has expired is closed.
+func (s *Session) checkOpen() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return maskAny(errgo.WithCausef(nil, SessionExpiredError, "session %s is closed", s.ID()))
+	}
+	if s.started && !s.lock.clock.Now().Before(s.lock.getHeldUntil()) {
+		s.closed = true
+		return maskAny(errgo.WithCausef(nil, SessionExpiredError, "session %s has expired", s.ID()))
+	}
+	return nil
+}
+
+// IsAlive returns true if the session is alive and will not expire within the safety margin.
+// IsAlive does not contact the API server.
+func (s *Session) IsAlive() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return !s.closed && s.lock.IsHeld()
+}
+
+// Close removes the liveness record of the session, freeing all locks bound to it.
+// The annotation that holds the liveness record is deleted.
+func (s *Session) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	s.lock.setHeldUntil(time.Time{})
+
+	// Get current state
+	ann, rv, extra, err := s.lock.getMeta()
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, found := ann[s.ID()]; !found {
+		// Nothing to remove
+		return nil
+	}
+
+	// Remove the liveness record
+	if _, err := s.lock.releaseIn(ann); err != nil {
+		return maskAny(err)
+	}
+	delete(ann, s.ID())
+	if err := s.lock.updateMeta(ann, rv, extra); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Run keeps the session alive until the given context is canceled or the session expires.
+// Renewal starts early enough to leave room for several attempts, based on the observed
+// round trip time. When that round trip time no longer leaves room for renewal attempts
+// within the ttl, Run gives up the session early and returns an error caused by RenewalUnsafeError.
+// The session is closed when Run returns.
+func (s *Session) Run(ctx context.Context) error {
+	defer s.Close()
+	for {
+		err := s.KeepAlive()
+		if IsSessionExpired(err) {
+			return maskAny(err)
+		}
+		if err := s.lock.checkRenewal(); err != nil {
+			// Step down while the session is still alive
+			return maskAny(err)
+		}
+		now := s.lock.clock.Now()
+		select {
+		case <-s.lock.after(s.lock.nextRenewal(now, err != nil).Sub(now)):
+			// Continue
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// heldUntil returns the expiration time of the liveness record of the session.
+// A zero time is returned when the session is closed or has never been alive.
+func (s *Session) heldUntil() time.Time {
+	s.mutex.Lock()
+	closed := s.closed
+	s.mutex.Unlock()
+
+	if closed {
+		return time.Time{}
+	}
+	return s.lock.getHeldUntil()
+}
+
+// aliveUntil returns the time until which the session is known to be alive.
+// If the session is not alive, a SessionExpiredError is returned.
+func (s *Session) aliveUntil() (time.Time, error) {
+	if !s.IsAlive() {
+		return time.Time{}, maskAny(errgo.WithCausef(nil, SessionExpiredError, "session %s is not alive", s.ID()))
+	}
+	return s.lock.getHeldUntil(), nil
+}
@@ -0,0 +1,185 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// TestSessionLockExcludesPlainLocks checks that a lock bound to a session that is kept alive
+// cannot be acquired by an instance without a session, long after the lock was acquired.
+func TestSessionLockExcludesPlainLocks(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	session, err := lock.NewSession("", "holder", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if err := session.KeepAlive(); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	holder, err := lock.NewKubeLock("", "holder", time.Minute, get, update, lock.WithClock(clock), lock.WithSession(session))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := holder.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	other, err := lock.NewKubeLock("", "other", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+
+	// Keep the session alive well beyond its ttl
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Second * 30)
+		if err := session.KeepAlive(); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+	}
+	if !holder.IsHeld() {
+		t.Fatalf("Expected holder to hold the lock")
+	}
+	if err := other.Acquire(); !lock.IsAlreadyLocked(err) {
+		t.Fatalf("Expected AlreadyLocked, got %v", err)
+	}
+	if owner, err := other.CurrentOwner(); err != nil || owner != "holder" {
+		t.Fatalf("Expected owner 'holder', got '%s' (%v)", owner, err)
+	}
+
+	// Closing the session frees the lock
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := other.Acquire(); err != nil {
+		t.Fatalf("Acquire after close failed: %v", err)
+	}
+}
+
+// TestSessionCloseRemovesAnnotation checks that closed sessions leave no annotations behind.
+func TestSessionCloseRemovesAnnotation(t *testing.T) {
+	get, update := newTestObject(t)
+	for i := 0; i < 3; i++ {
+		session, err := lock.NewSession("", "owner", time.Minute, get, update)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		if err := session.KeepAlive(); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+		if err := session.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	ann, _, _, err := get()
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(ann) != 0 {
+		t.Errorf("Expected no annotations, got %v", ann)
+	}
+}
+
+// TestSessionLockMaxHold checks that a lock bound to a session that is kept alive is no longer
+// considered held once it reaches the maximum hold duration, minus the safety margin,
+// so it is never held by two owners at once.
+func TestSessionLockMaxHold(t *testing.T) {
+	get, update := newTestObject(t)
+	clock := locktest.NewClock(time.Time{})
+	start := clock.Now()
+	session, err := lock.NewSession("", "holder", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if err := session.KeepAlive(); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	holder, err := lock.NewKubeLock("", "holder", time.Minute, get, update, lock.WithClock(clock), lock.WithSession(session),
+		lock.WithMaxHoldDuration(2*time.Minute), lock.WithSafetyMargin(10*time.Second))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := holder.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	other, err := lock.NewKubeLock("", "other", time.Minute, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+
+	acquired := false
+	for i := 0; i < 30; i++ {
+		clock.Advance(10 * time.Second)
+		if err := session.KeepAlive(); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+		elapsed := clock.Now().Sub(start)
+		held := holder.IsHeld()
+		if expected := elapsed < 110*time.Second; held != expected {
+			t.Fatalf("Expected IsHeld to be %v after %s, got %v", expected, elapsed, held)
+		}
+		if !acquired {
+			err := other.Acquire()
+			if err == nil {
+				acquired = true
+			} else if !lock.IsAlreadyLocked(err) {
+				t.Fatalf("Acquire by other failed: %v", err)
+			}
+		}
+		if acquired && held {
+			t.Fatalf("Lock held by two owners after %s", elapsed)
+		}
+	}
+	if !acquired {
+		t.Errorf("Expected other to acquire the lock after the maximum hold duration")
+	}
+}
+
+// TestSessionIsAliveDuringKeepAlive checks that IsAlive does not wait for a KeepAlive
+// that is waiting for the API server.
+func TestSessionIsAliveDuringKeepAlive(t *testing.T) {
+	get, update := newTestObject(t)
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	blocking := false
+	slowUpdate := func(ann map[string]string, rv string, extra interface{}) error {
+		if blocking {
+			close(entered)
+			<-unblock
+		}
+		return update(ann, rv, extra)
+	}
+	session, err := lock.NewSession("", "owner", time.Minute, get, slowUpdate)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if err := session.KeepAlive(); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+
+	blocking = true
+	done := make(chan error, 1)
+	go func() {
+		done <- session.KeepAlive()
+	}()
+	<-entered
+
+	alive := make(chan bool, 1)
+	go func() {
+		alive <- session.IsAlive()
+	}()
+	select {
+	case isAlive := <-alive:
+		if !isAlive {
+			t.Errorf("Expected session to be alive")
+		}
+	case <-time.After(time.Second * 5):
+		t.Errorf("IsAlive waits for KeepAlive")
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownHandler releases locks when the process is asked to stop, so other instances
+// can take over right away, instead of waiting for the locks to expire.
+type ShutdownHandler struct {
+	deadline time.Duration
+
+	mutex sync.Mutex
+	funcs []func() error
+}
+
+const (
+	defaultShutdownDeadline = time.Second * 10
+)
+
+// NewShutdownHandler creates a new ShutdownHandler that waits at most the given deadline
+// for all locks to be released. If the deadline is 0, 10 seconds is used.
+func NewShutdownHandler(deadline time.Duration) *ShutdownHandler {
+	if deadline == 0 {
+		deadline = defaultShutdownDeadline
+	}
+	return &ShutdownHandler{
+		deadline: deadline,
+	}
+}
+
+// ShutdownSignals returns a channel that receives SIGTERM & SIGINT,
+// and a function that stops the delivery of these signals to the channel.
+func ShutdownSignals() (<-chan os.Signal, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	return signals, func() { signal.Stop(signals) }
+}
+
+// Register adds a lock that must be released on shutdown.
+// Locks that we do not hold at that time are left alone.
+func (h *ShutdownHandler) Register(l KubeLock) {
+	h.RegisterFunc(func() error {
+		if err := l.Release(); err != nil && !IsNotLockedByMe(err) {
+			return maskAny(err)
+		}
+		return nil
+	})
+}
+
+// RegisterFunc adds a function that is called on shutdown,
+// such as Manager.ReleaseAll or Session.Close.
+func (h *ShutdownHandler) RegisterFunc(fn func() error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.funcs = append(h.funcs, fn)
+}
+
+// Run waits until a signal is received on the given channel (see ShutdownSignals),
+// or the given context is canceled. It then calls Shutdown.
+// Return from your main function when Run returns.
+func (h *ShutdownHandler) Run(ctx context.Context, signals <-chan os.Signal) error {
+	select {
+	case <-signals:
+	case <-ctx.Done():
+	}
+	if err := h.Shutdown(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// RunOnSignals is Run with the channel returned by ShutdownSignals.
+// The delivery of signals to that channel is stopped when RunOnSignals returns.
+func (h *ShutdownHandler) RunOnSignals(ctx context.Context) error {
+	signals, stop := ShutdownSignals()
+	defer stop()
+	if err := h.Run(ctx, signals); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Shutdown releases all registered locks at once and waits until they are released,
+// or the deadline has passed.
+// If one or more locks could not be released in time, the first error is returned.
+func (h *ShutdownHandler) Shutdown() error {
+	h.mutex.Lock()
+	funcs := append([]func() error(nil), h.funcs...)
+	h.mutex.Unlock()
+
+	errors := make(chan error, len(funcs))
+	for _, fn := range funcs {
+		go func(fn func() error) {
+			errors <- fn()
+		}(fn)
+	}
+	timeout := time.After(h.deadline)
+	var firstErr error
+	for range funcs {
+		select {
+		case err := <-errors:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case <-timeout:
+			return maskAny(fmt.Errorf("locks not released within %s", h.deadline))
+		}
+	}
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package lock_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+// TestShutdownOnSignal checks that a signal on the given channel releases all registered locks.
+func TestShutdownOnSignal(t *testing.T) {
+	get, update := newTestObject(t)
+	l, err := lock.NewKubeLock("", "owner", time.Hour, get, update)
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := l.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	h := lock.NewShutdownHandler(time.Second)
+	h.Register(l)
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Run(context.Background(), signals)
+	}()
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Run did not return after signal")
+	}
+	if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+		t.Errorf("Expected lock to be released, got owner '%s' (%v)", owner, err)
+	}
+}
+
+// TestShutdownDeadline checks that Run returns an error at the deadline when a lock
+// is not released in time, after its context is canceled.
+func TestShutdownDeadline(t *testing.T) {
+	h := lock.NewShutdownHandler(time.Millisecond * 50)
+	block := make(chan struct{})
+	defer close(block)
+	h.RegisterFunc(func() error {
+		<-block
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Run(ctx, nil)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error when the deadline passed")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Run did not return at the deadline")
+	}
+}
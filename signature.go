@@ -0,0 +1,55 @@
+package lock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/juju/errgo"
+)
+
+// SignaturePolicy determines how a lock treats lock data with a bad or missing signature.
+type SignaturePolicy int
+
+const (
+	// SignaturePolicyCorrupt treats lock data with a bad or missing signature as corrupt.
+	// All operations on such a lock fail with an InvalidSignatureError until the
+	// lock data is removed.
+	SignaturePolicyCorrupt SignaturePolicy = iota
+	// SignaturePolicyFree treats lock data with a bad or missing signature as if
+	// the lock is not held by anyone.
+	SignaturePolicyFree
+)
+
+// sign returns the signature of the given lock data, to be stored in the annotation with given key.
+// The signature covers the annotation key and all fields of the lock data, except the signature itself.
+// It does not cover the identity of the resource, which the lock does not know, so signed lock data
+// that is copied to the same annotation of another resource is accepted there.
+func (l *kubeLock) sign(annotationKey string, lockData LockData) (string, error) {
+	lockData.Signature = ""
+	raw, err := json.Marshal(lockData)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(annotationKey))
+	mac.Write([]byte{0})
+	mac.Write(raw)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify checks the signature of the given lock data, read from the annotation with given key.
+func (l *kubeLock) verify(annotationKey string, lockData LockData) error {
+	if lockData.Signature == "" {
+		return maskAny(errgo.WithCausef(nil, InvalidSignatureError, "lock data of %s is not signed", lockData.Owner))
+	}
+	expected, err := l.sign(annotationKey, lockData)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !hmac.Equal([]byte(expected), []byte(lockData.Signature)) {
+		return maskAny(errgo.WithCausef(nil, InvalidSignatureError, "lock data of %s has a bad signature", lockData.Owner))
+	}
+	return nil
+}
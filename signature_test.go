@@ -0,0 +1,117 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+)
+
+var (
+	testSigningKey = []byte("secret")
+)
+
+// TestSignedLockData checks that correctly signed lock data is accepted under both policies.
+func TestSignedLockData(t *testing.T) {
+	for _, policy := range []lock.SignaturePolicy{lock.SignaturePolicyCorrupt, lock.SignaturePolicyFree} {
+		get, update := newTestObject(t)
+		holder, err := lock.NewKubeLock("", "holder", time.Minute, get, update, lock.WithSigningKey(testSigningKey, policy))
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if err := holder.Acquire(); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		other, err := lock.NewKubeLock("", "other", time.Minute, get, update, lock.WithSigningKey(testSigningKey, policy))
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if owner, err := other.CurrentOwner(); err != nil || owner != "holder" {
+			t.Errorf("Policy %d: expected owner 'holder', got '%s' (%v)", policy, owner, err)
+		}
+		if err := other.Acquire(); !lock.IsAlreadyLocked(err) {
+			t.Errorf("Policy %d: expected AlreadyLockedError, got %v", policy, err)
+		}
+	}
+}
+
+// TestUnsignedLockData checks that lock data with a missing or bad signature is rejected
+// as corrupt or treated as free, depending on the policy.
+func TestUnsignedLockData(t *testing.T) {
+	writers := map[string][]lock.Option{
+		"missing": nil,
+		"bad":     {lock.WithSigningKey([]byte("other secret"), lock.SignaturePolicyCorrupt)},
+	}
+	for name, options := range writers {
+		// Corrupt policy
+		get, update := newTestObject(t)
+		writer, err := lock.NewKubeLock("", "writer", time.Minute, get, update, options...)
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if err := writer.Acquire(); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		l, err := lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithSigningKey(testSigningKey, lock.SignaturePolicyCorrupt))
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if _, err := l.CurrentOwner(); !lock.IsInvalidSignature(err) {
+			t.Errorf("Signature %s: expected InvalidSignatureError from CurrentOwner, got %v", name, err)
+		}
+		if err := l.Acquire(); !lock.IsInvalidSignature(err) {
+			t.Errorf("Signature %s: expected InvalidSignatureError from Acquire, got %v", name, err)
+		}
+
+		// Free policy
+		get, update = newTestObject(t)
+		writer, err = lock.NewKubeLock("", "writer", time.Minute, get, update, options...)
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if err := writer.Acquire(); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		l, err = lock.NewKubeLock("", "owner", time.Minute, get, update, lock.WithSigningKey(testSigningKey, lock.SignaturePolicyFree))
+		if err != nil {
+			t.Fatalf("NewKubeLock failed: %v", err)
+		}
+		if owner, err := l.CurrentOwner(); err != nil || owner != "" {
+			t.Errorf("Signature %s: expected no owner, got '%s' (%v)", name, owner, err)
+		}
+		if err := l.Acquire(); err != nil {
+			t.Errorf("Signature %s: expected Acquire to succeed, got %v", name, err)
+		}
+		if owner, err := l.CurrentOwner(); err != nil || owner != "owner" {
+			t.Errorf("Signature %s: expected owner 'owner', got '%s' (%v)", name, owner, err)
+		}
+	}
+}
+
+// TestSignedLockDataMovedToOtherKey checks that signed lock data copied to another
+// annotation is rejected.
+func TestSignedLockDataMovedToOtherKey(t *testing.T) {
+	get, update := newTestObject(t)
+	holder, err := lock.NewKubeLock("pulcy.com/lock-a", "holder", time.Minute, get, update, lock.WithSigningKey(testSigningKey, lock.SignaturePolicyCorrupt))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := holder.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	ann, rv, extra, err := get()
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	ann["pulcy.com/lock-b"] = ann["pulcy.com/lock-a"]
+	if err := update(ann, rv, extra); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	l, err := lock.NewKubeLock("pulcy.com/lock-b", "other", time.Minute, get, update, lock.WithSigningKey(testSigningKey, lock.SignaturePolicyCorrupt))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if _, err := l.CurrentOwner(); !lock.IsInvalidSignature(err) {
+		t.Errorf("Expected InvalidSignatureError, got %v", err)
+	}
+}
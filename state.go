@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"time"
+)
+
+// LockState is a snapshot of the local state of a lock.
+// Locks bound to a session are renewed by that session, so their expiration time & renewal
+// attempts are those of the session.
+type LockState struct {
+	// AnnotationKey of the annotation that holds the lock data.
+	AnnotationKey string `json:"annotation_key"`
+	// OwnerID that we use for the lock.
+	OwnerID string `json:"owner_id"`
+	// Held is set when we consider ourselves the holder of the lock (see IsHeld).
+	Held bool `json:"held"`
+	// HeldUntil is the expiration time of the lock we hold, zero if we do not hold it.
+	HeldUntil time.Time `json:"held_until"`
+	// LastAttempt is the time of the last attempt to acquire or renew the lock.
+	LastAttempt time.Time `json:"last_attempt"`
+	// LastSuccess is the time of the last successful attempt to acquire or renew the lock.
+	LastSuccess time.Time `json:"last_success"`
+	// LastRoundTrip is the duration of the last get & update of the lock data.
+	LastRoundTrip time.Duration `json:"last_round_trip"`
+	// LastError is the error of the last attempt, empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// State returns a snapshot of the local state of the lock.
+// State does not contact the API server.
+func (l *kubeLock) State() LockState {
+	held := l.IsHeld()
+	heldUntil := l.currentHeldUntil()
+	var session *LockState
+	if l.session != nil {
+		sessionState := l.session.lock.State()
+		session = &sessionState
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state := LockState{
+		AnnotationKey: l.annotationKey,
+		OwnerID:       l.ownerID,
+		Held:          held,
+		HeldUntil:     heldUntil,
+		LastAttempt:   l.lastAttempt,
+		LastSuccess:   l.lastSuccess,
+		LastRoundTrip: l.lastRoundTrip,
+	}
+	if l.lastError != nil {
+		state.LastError = l.lastError.Error()
+	}
+	if session != nil && !state.HeldUntil.IsZero() {
+		// Renewed by the session
+		if session.LastAttempt.After(state.LastAttempt) {
+			state.LastAttempt, state.LastError = session.LastAttempt, session.LastError
+		}
+		if session.LastSuccess.After(state.LastSuccess) {
+			state.LastSuccess = session.LastSuccess
+		}
+	}
+	return state
+}
+
+// recordAttempt records the outcome of an attempt to acquire or renew the lock,
+// started at the given time.
+func (l *kubeLock) recordAttempt(start time.Time, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastAttempt = start
+	l.lastError = err
+	if err == nil {
+		l.lastSuccess = start
+	}
+}
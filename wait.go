@@ -0,0 +1,109 @@
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+// MetaWatcher starts watching the resource that holds the lock data.
+// The returned channel must receive a value whenever the resource changes
+// and must be closed when the watch ends or the given context is canceled.
+type MetaWatcher func(ctx context.Context) (<-chan struct{}, error)
+
+const (
+	defaultPollInterval = time.Second * 2
+)
+
+// WaitUntilFree blocks until the lock is not held by anyone, or the given context is canceled.
+// A lock that has expired is considered free.
+func (l *kubeLock) WaitUntilFree(ctx context.Context) error {
+	_, err := l.wait(ctx, func(owner string) bool {
+		return owner == ""
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// WaitForOwnerChange blocks until the owner of the lock is different from the given owner,
+// or the given context is canceled.
+// It returns the new owner, which is "" when the lock is free.
+// A lock that has expired is considered free.
+func (l *kubeLock) WaitForOwnerChange(ctx context.Context, owner string) (string, error) {
+	newOwner, err := l.wait(ctx, func(current string) bool {
+		return current != owner
+	})
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return newOwner, nil
+}
+
+// wait fetches the lock data until the given condition returns true for the current
+// owner of the lock, or the given context is canceled.
+// The lock data is fetched every poll interval, when the watcher reports a change
+// and when the lock expires.
+func (l *kubeLock) wait(ctx context.Context, condition func(owner string) bool) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var changes <-chan struct{}
+	for {
+		// Start watching (again)
+		if changes == nil && l.watchMeta != nil {
+			if c, err := l.watchMeta(ctx); err == nil {
+				changes = c
+			}
+		}
+
+		// Get current owner
+		owner, expiresAt, err := l.activeOwner()
+		if err != nil {
+			return "", maskAny(err)
+		}
+		if condition(owner) {
+			return owner, nil
+		}
+
+		// Wait for something to happen
+		delay := l.pollInterval
+		if owner != "" {
+			if untilExpiry := expiresAt.Sub(l.clock.Now()); untilExpiry < delay {
+				delay = untilExpiry
+			}
+		}
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+			}
+		case <-l.after(delay):
+			// Poll again
+		case <-ctx.Done():
+			return "", maskAny(ctx.Err())
+		}
+	}
+}
+
+// activeOwner fetches the owner of the lock & the time until which it is held.
+// If the lock is free or has expired, "" is returned.
+func (l *kubeLock) activeOwner() (string, time.Time, error) {
+	ann, _, _, err := l.getMeta()
+	if err != nil {
+		return "", time.Time{}, maskAny(err)
+	}
+	lockData, found, err := l.readLockData(ann)
+	if err != nil {
+		return "", time.Time{}, maskAny(err)
+	} else if !found || lockData.Owner == "" {
+		return "", time.Time{}, nil
+	}
+	end, err := l.holdEnd(ann, lockData)
+	if err != nil {
+		return "", time.Time{}, maskAny(err)
+	} else if !l.clock.Now().Before(end) {
+		return "", time.Time{}, nil
+	}
+	return lockData.Owner, end, nil
+}
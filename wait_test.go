@@ -0,0 +1,131 @@
+package lock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lock "github.com/pulcy/kube-lock"
+	"github.com/pulcy/kube-lock/locktest"
+)
+
+// newWaitLocks creates a lock held by "holder" and a lock of "waiter" on the same object.
+func newWaitLocks(t *testing.T, clock *locktest.Clock, ttl time.Duration) (holder, waiter lock.ExtendedLock) {
+	get, update := newTestObject(t)
+	holder, err := lock.NewKubeLock("", "holder", ttl, get, update, lock.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	if err := holder.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	waiter, err = lock.NewKubeLock("", "waiter", ttl, get, update, lock.WithClock(clock), lock.WithPollInterval(time.Second*10))
+	if err != nil {
+		t.Fatalf("NewKubeLock failed: %v", err)
+	}
+	return holder, waiter
+}
+
+// awaitTimer blocks until a wait started in the background waits for a timer of the given clock.
+func awaitTimer(t *testing.T, clock *locktest.Clock) {
+	deadline := time.Now().Add(time.Second * 10)
+	for clock.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Wait did not start a timer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// awaitResult returns the result of a wait started in the background.
+func awaitResult(t *testing.T, done <-chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second * 10):
+		t.Fatal("Wait did not return")
+		return nil
+	}
+}
+
+// TestWaitUntilFreeOnRelease checks that WaitUntilFree returns at the next poll after the lock is released.
+func TestWaitUntilFreeOnRelease(t *testing.T) {
+	clock := locktest.NewClock(time.Time{})
+	holder, waiter := newWaitLocks(t, clock, time.Hour)
+	done := make(chan error, 1)
+	go func() {
+		done <- waiter.WaitUntilFree(context.Background())
+	}()
+	awaitTimer(t, clock)
+	if err := holder.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	clock.Advance(time.Second * 10)
+	if err := awaitResult(t, done); err != nil {
+		t.Errorf("WaitUntilFree failed: %v", err)
+	}
+}
+
+// TestWaitUntilFreeOnExpiry checks that WaitUntilFree returns when the lock expires,
+// before the next poll.
+func TestWaitUntilFreeOnExpiry(t *testing.T) {
+	clock := locktest.NewClock(time.Time{})
+	_, waiter := newWaitLocks(t, clock, time.Second*3)
+	done := make(chan error, 1)
+	go func() {
+		done <- waiter.WaitUntilFree(context.Background())
+	}()
+	awaitTimer(t, clock)
+	clock.Advance(time.Second * 3)
+	if err := awaitResult(t, done); err != nil {
+		t.Errorf("WaitUntilFree failed: %v", err)
+	}
+}
+
+// TestWaitForOwnerChange checks that WaitForOwnerChange returns the new owner
+// at the next poll after the lock changed hands.
+func TestWaitForOwnerChange(t *testing.T) {
+	clock := locktest.NewClock(time.Time{})
+	holder, waiter := newWaitLocks(t, clock, time.Hour)
+	type result struct {
+		owner string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		owner, err := waiter.WaitForOwnerChange(context.Background(), "holder")
+		done <- result{owner, err}
+	}()
+	awaitTimer(t, clock)
+	if err := holder.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := waiter.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	clock.Advance(time.Second * 10)
+	select {
+	case r := <-done:
+		if r.err != nil || r.owner != "waiter" {
+			t.Errorf("Expected new owner 'waiter', got '%s' (%v)", r.owner, r.err)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("WaitForOwnerChange did not return")
+	}
+}
+
+// TestWaitCanceled checks that WaitUntilFree returns the error of the context when it is canceled.
+func TestWaitCanceled(t *testing.T) {
+	clock := locktest.NewClock(time.Time{})
+	_, waiter := newWaitLocks(t, clock, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- waiter.WaitUntilFree(ctx)
+	}()
+	awaitTimer(t, clock)
+	cancel()
+	if err := awaitResult(t, done); err == nil {
+		t.Error("Expected an error after cancel")
+	}
+}